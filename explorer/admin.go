@@ -0,0 +1,117 @@
+// admin.go
+package explorer
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// errNoAdminToken is returned by NewApp when admin endpoints are enabled
+// without a token to protect them.
+var errNoAdminToken = errors.New("admin endpoints require an admin token")
+
+// requireAdmin wraps an admin endpoint so it only runs for requests carrying
+// the admin token as "Authorization: Bearer <token>".
+func (a *App) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if token == auth || subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			a.respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAPIAdminIntegrity runs SQLite's consistency checks on the database:
+// integrity_check, quick_check and foreign_key_check. These PRAGMAs can't go
+// through the SELECT-only query endpoints, so they get this admin-only route.
+func (a *App) handleAPIAdminIntegrity(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	ctx := r.Context()
+	integrity, err := a.pragmaMessages(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to run integrity check", err)
+		return
+	}
+	quick, err := a.pragmaMessages(ctx, "PRAGMA quick_check")
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to run quick check", err)
+		return
+	}
+	violations, err := a.foreignKeyCheck(ctx)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to run foreign key check", err)
+		return
+	}
+
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"ok":                isOK(integrity) && isOK(quick) && len(violations) == 0,
+		"integrity_check":   integrity,
+		"quick_check":       quick,
+		"foreign_key_check": violations,
+	})
+}
+
+// pragmaMessages runs a checking PRAGMA and returns its result rows, which
+// are a single "ok" when nothing is wrong.
+func (a *App) pragmaMessages(ctx context.Context, pragma string) ([]string, error) {
+	rows, err := a.conn().QueryContext(ctx, pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// foreignKeyCheck returns the rows violating foreign key constraints, each
+// with its table, rowid (nil for WITHOUT ROWID tables), the parent table and
+// the index of the foreign key in PRAGMA foreign_key_list(table).
+func (a *App) foreignKeyCheck(ctx context.Context) ([]map[string]interface{}, error) {
+	rows, err := a.conn().QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	violations := []map[string]interface{}{}
+	for rows.Next() {
+		var (
+			table, parent string
+			rowid         sql.NullInt64
+			fkid          int
+		)
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return nil, err
+		}
+		v := map[string]interface{}{"table": table, "rowid": nil, "parent": parent, "fkid": fkid}
+		if rowid.Valid {
+			v["rowid"] = rowid.Int64
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
+
+// isOK reports whether a checking PRAGMA found no problems.
+func isOK(messages []string) bool {
+	return len(messages) == 1 && messages[0] == "ok"
+}
@@ -0,0 +1,121 @@
+// apijson.go
+package explorer
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonpCallbackRe matches the JSONP callback names ?_callback= accepts: a
+// JavaScript identifier, optionally dotted, e.g. "handle" or "app.onRows".
+var jsonpCallbackRe = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)*$`)
+
+// maxCallbackLen caps the length of JSONP callback names.
+const maxCallbackLen = 64
+
+// apiWriter carries the per-request options for encoding an API response.
+type apiWriter struct {
+	http.ResponseWriter
+	pretty   bool   // Indent the JSON
+	callback string // Wrap the JSON in a call to this JSONP callback, if set
+	errorsOK bool   // Send errors with status 200, see errorEnvelope
+}
+
+// withAPIOptions reads the response options of API requests: ?_pretty= (see
+// wantsPretty), ?_errors=200 and, when JSONP is allowed, ?_callback=.
+func (a *App) withAPIOptions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		aw := apiWriter{ResponseWriter: w, pretty: wantsPretty(r)}
+		switch r.URL.Query().Get("_errors") {
+		case "":
+		case "200":
+			aw.errorsOK = true
+		default:
+			a.respondWithError(w, http.StatusBadRequest, "_errors must be '200'")
+			return
+		}
+		if callback := r.URL.Query().Get("_callback"); callback != "" {
+			switch {
+			case !a.allowJSONP:
+				a.respondWithError(w, http.StatusBadRequest, "JSONP is disabled on this server")
+				return
+			case r.Method != http.MethodGet && r.Method != http.MethodHead:
+				a.respondWithError(w, http.StatusBadRequest, "_callback is only allowed on GET requests")
+				return
+			case len(callback) > maxCallbackLen || !jsonpCallbackRe.MatchString(callback):
+				a.respondWithError(w, http.StatusBadRequest, "_callback must be a JavaScript identifier, optionally dotted")
+				return
+			}
+			aw.callback = callback
+		}
+		next.ServeHTTP(aw, r)
+	})
+}
+
+// wantsPretty reports whether r's JSON response should be indented: when
+// ?_pretty=1 is given, or when a browser asks for the page (Accept includes
+// text/html) and ?_pretty=0 isn't. Other clients get compact JSON.
+func wantsPretty(r *http.Request) bool {
+	switch r.URL.Query().Get("_pretty") {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// marshalJSON encodes payload for w, indented if w asks for it.
+func marshalJSON(w http.ResponseWriter, payload interface{}) ([]byte, error) {
+	if aw, ok := w.(apiWriter); ok && aw.pretty {
+		return json.MarshalIndent(payload, "", "  ")
+	}
+	return json.Marshal(payload)
+}
+
+// errorEnvelope returns the body and status of an error response for w. With
+// ?_errors=200, for clients that treat any other status as a failed request,
+// the status is 200 and the body gets "ok": false and the real "status"
+// added. Otherwise both are left as they are.
+func errorEnvelope(w http.ResponseWriter, code int, body []byte) (int, []byte) {
+	aw, ok := w.(apiWriter)
+	if !ok || !aw.errorsOK || code < 400 {
+		return code, body
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return code, body
+	}
+	fields["ok"] = json.RawMessage("false")
+	fields["status"] = json.RawMessage(strconv.Itoa(code))
+	wrapped, err := marshalJSON(w, fields)
+	if err != nil {
+		return code, body
+	}
+	return http.StatusOK, wrapped
+}
+
+// writeJSON writes an encoded JSON response with the given content type, or
+// as a JSONP script calling the request's callback. The leading comment
+// guards against the callback being read as the start of other content.
+func writeJSON(w http.ResponseWriter, code int, contentType string, body []byte) {
+	if aw, ok := w.(apiWriter); ok && aw.callback != "" {
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(code)
+		w.Write([]byte("/**/" + aw.callback + "("))
+		w.Write(body)
+		w.Write([]byte(");"))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(body)
+}
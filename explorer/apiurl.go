@@ -0,0 +1,128 @@
+// apiurl.go
+package explorer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// APIRequest is the JSON API request that returns the data of an HTML page,
+// shown on table and query pages to help people find the API.
+type APIRequest struct {
+	URL  string // Absolute URL of the API endpoint, with the page's parameters
+	Curl string // Ready-to-paste curl command making the request
+}
+
+// tableAPIRequest returns the /api/table/{name} request for a page of the
+// table view, with its search, sort order and column choices.
+func (a *App) tableAPIRequest(r *http.Request, tableName string, page int, view tableView) *APIRequest {
+	query := viewParams(view)
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	return a.apiGetRequest(r, "/api/table/"+url.PathEscape(tableName), query)
+}
+
+// viewParams returns the query parameters selecting view: its search, sort
+// order and column choices.
+func viewParams(view tableView) url.Values {
+	query := url.Values{}
+	if view.Search != "" {
+		query.Set("_search", view.Search)
+	}
+	if len(view.Sort) > 0 {
+		query.Set("_sort", sortString(view.Sort))
+	}
+	if len(view.Columns) > 0 {
+		query.Set("_cols", strings.Join(view.Columns, ","))
+	}
+	if view.Rowid {
+		query.Set("_rowid", "on")
+	}
+	return query
+}
+
+// ExportLink is a download of every row of a table view in one format.
+type ExportLink struct {
+	Format   string // Label of the format, e.g. "CSV"
+	URL      string
+	FileName string // Name browsers save the download under
+}
+
+// tableExportLinks returns the downloads of every row of the table view, in
+// its order and with its search and columns, as /api/table/{name}?_all=on
+// URLs.
+func tableExportLinks(tableName string, view tableView) []ExportLink {
+	query := viewParams(view)
+	query.Set("_all", "on")
+	path := "/api/table/" + url.PathEscape(tableName) + "?"
+	var links []ExportLink
+	for _, format := range []struct{ label, value string }{{"CSV", "csv"}, {"JSON", "json"}} {
+		query.Set("_format", format.value)
+		links = append(links, ExportLink{
+			Format:   format.label,
+			URL:      path + query.Encode(),
+			FileName: exportFileName(tableName) + "." + format.value,
+		})
+	}
+	return links
+}
+
+// randomAPIRequest returns the /api/table/{name}/random request for a random
+// sample page.
+func (a *App) randomAPIRequest(r *http.Request, tableName string, n int, method string) *APIRequest {
+	query := url.Values{"n": {strconv.Itoa(n)}}
+	if method != "rowid" {
+		query.Set("method", method)
+	}
+	return a.apiGetRequest(r, "/api/table/"+url.PathEscape(tableName)+"/random", query)
+}
+
+// queryAPIRequest returns the API request running query against dbName. The
+// GET form of /api/query can't bind parameters, so queries with parameters
+// are POSTed as JSON instead. The curl command sends the request's tenant
+// header along if the query needs it.
+func (a *App) queryAPIRequest(r *http.Request, dbName, query string, params []QueryParam) *APIRequest {
+	path := "/api/query"
+	if dbName != a.dbName {
+		path = "/api/db/" + url.PathEscape(dbName) + "/query"
+	}
+	var req *APIRequest
+	if len(params) == 0 {
+		req = a.apiGetRequest(r, path, url.Values{"sql": {query}})
+	} else {
+		values := make(map[string]string, len(params))
+		for _, p := range params {
+			values[p.Name] = p.Value
+		}
+		body, _ := json.Marshal(map[string]interface{}{"sql": query, "params": values})
+		endpoint := a.requestOrigin(r) + path
+		req = &APIRequest{
+			URL:  endpoint,
+			Curl: "curl -X POST -H 'Content-Type: application/json' -d " + shellQuote(string(body)) + " " + shellQuote(endpoint),
+		}
+	}
+	if tenant, _ := a.tenantArgs(r, query); len(tenant) > 0 {
+		header := shellQuote(a.tenantHeader + ": " + r.Header.Get(a.tenantHeader))
+		req.Curl = "curl -H " + header + strings.TrimPrefix(req.Curl, "curl")
+	}
+	return req
+}
+
+// apiGetRequest returns the GET request for the API endpoint at path with
+// the query parameters query.
+func (a *App) apiGetRequest(r *http.Request, path string, query url.Values) *APIRequest {
+	endpoint := a.requestOrigin(r) + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	return &APIRequest{URL: endpoint, Curl: "curl " + shellQuote(endpoint)}
+}
+
+// shellQuote quotes s as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
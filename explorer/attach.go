@@ -0,0 +1,161 @@
+// attach.go
+package explorer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// attachNameRe restricts the schema names of attached databases to plain
+// identifiers, so they can be used unquoted in queries like "SELECT * FROM
+// sales.orders".
+var attachNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseDBArg splits a -db argument of the form "label=path" into its label
+// and path; arguments without a label return an empty one. The part before
+// "=" is only a label if it has no slash, so "./a=b.db" is a path. Labels
+// name the database in the UI, in /db/{label}/ routes and as its schema name
+// in queries, so they are limited to letters, digits and underscores, which
+// are also safe in URLs.
+func ParseDBArg(arg string) (label, path string, err error) {
+	i := strings.Index(arg, "=")
+	if i < 0 || strings.ContainsAny(arg[:i], `/\`) {
+		return "", arg, nil
+	}
+	label, path = arg[:i], arg[i+1:]
+	if !attachNameRe.MatchString(label) {
+		return "", "", fmt.Errorf("invalid database label %q in %q: use letters, digits and underscores, starting with a letter or underscore", label, arg)
+	}
+	if path == "" {
+		return "", "", fmt.Errorf("missing database path in %q", arg)
+	}
+	return label, path, nil
+}
+
+// attachedDB is an extra database file attached to every connection with
+// ATTACH DATABASE under the schema name Name.
+type attachedDB struct {
+	Name string
+	Path string
+}
+
+// parseAttachments pairs the database files to attach with their schema
+// names: the label at the same index of names if there is a non-empty one,
+// and otherwise the file name without extension, e.g. "/data/sales.db" or
+// "/data/sales.db.gz" becomes "sales".
+// mainName is the name of the primary database, which they must not clash with.
+func parseAttachments(paths, names []string, mainName string) ([]attachedDB, error) {
+	// Schema names are case-insensitive in SQLite.
+	seen := map[string]bool{"main": true, "temp": true, strings.ToLower(mainName): true}
+	attached := make([]attachedDB, 0, len(paths))
+	for i, path := range paths {
+		name := baseName(path)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		if !attachNameRe.MatchString(name) {
+			return nil, fmt.Errorf("cannot attach %s: %q is not a valid schema name (use letters, digits and underscores)", path, name)
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("cannot attach %s: schema name %q is already in use", path, name)
+		}
+		seen[strings.ToLower(name)] = true
+		attached = append(attached, attachedDB{Name: name, Path: path})
+	}
+	return attached, nil
+}
+
+// attachConnector opens SQLite connections and attaches the extra databases
+// to each one. ATTACH only applies to the connection it runs on, so it has to
+// be repeated for every connection the pool opens.
+type attachConnector struct {
+	dsn      string
+	attached []attachedDB
+	driver   driver.Driver
+}
+
+// newAttachConnector returns a connector that opens dsn with d and attaches
+// databases, always read-only.
+func newAttachConnector(d driver.Driver, dsn string, attached []attachedDB) *attachConnector {
+	return &attachConnector{dsn: dsn, attached: attached, driver: d}
+}
+
+// Connect implements driver.Connector.
+func (c *attachConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.attach(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Driver implements driver.Connector.
+func (c *attachConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// attach runs ATTACH DATABASE for each extra database on a new connection.
+// It only needs driver.ExecerContext, which both SQLite drivers implement.
+func (c *attachConnector) attach(ctx context.Context, conn driver.Conn) error {
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return fmt.Errorf("the SQLite driver can't run ATTACH DATABASE on its connections")
+	}
+	for _, db := range c.attached {
+		uri := fmt.Sprintf("file:%s?mode=ro", db.Path)
+		args := []driver.NamedValue{{Ordinal: 1, Value: uri}}
+		if _, err := execer.ExecContext(ctx, "ATTACH DATABASE ? AS "+quoteIdent(db.Name), args); err != nil {
+			return fmt.Errorf("failed to attach %s: %w", db.Path, err)
+		}
+	}
+	return nil
+}
+
+// databaseConn returns the connection pool /db/{name}/query runs queries on:
+// the main pool for the main database, and for an attached one a read-only
+// pool of its own file, so that unqualified table names resolve to its
+// tables. These pools are opened on first use, and closed along with the
+// main pool when it is replaced or the App is closed.
+func (a *App) databaseConn(name string) (*sql.DB, error) {
+	if name == a.dbName {
+		return a.conn(), nil
+	}
+	a.scopedMu.Lock()
+	defer a.scopedMu.Unlock()
+	for _, att := range a.attachments() {
+		if att.Name != name {
+			continue
+		}
+		if db, ok := a.scoped[att]; ok {
+			return db, nil
+		}
+		db, err := openDB(a.driver, att.Path, false, a.dsnParams, nil)
+		if err != nil {
+			return nil, err
+		}
+		if a.scoped == nil {
+			a.scoped = make(map[attachedDB]*sql.DB)
+		}
+		a.scoped[att] = db
+		return db, nil
+	}
+	return nil, fmt.Errorf("database %q not found", name)
+}
+
+// takeScopedConns empties the pools of databaseConn, returning them for the
+// caller to close.
+func (a *App) takeScopedConns() map[attachedDB]*sql.DB {
+	a.scopedMu.Lock()
+	defer a.scopedMu.Unlock()
+	scoped := a.scoped
+	a.scoped = nil
+	return scoped
+}
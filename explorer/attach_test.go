@@ -0,0 +1,106 @@
+// attach_test.go
+package explorer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newAttachedTestApp returns an App for a main database attached to another
+// one, sales, both with a table t holding their own name.
+func newAttachedTestApp(t *testing.T) *App {
+	sales := newTestDB(t, "sales.db", "CREATE TABLE t (db TEXT); INSERT INTO t VALUES ('sales');")
+	return newTestApp(t, "CREATE TABLE t (db TEXT); INSERT INTO t VALUES ('main');", Config{
+		Name:        "main_db",
+		AttachPaths: []string{sales},
+	})
+}
+
+// apiQueryRows runs GET target on app and returns the rows of its JSON
+// response.
+func apiQueryRows(t *testing.T, app *App, target string) [][]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", target, rec.Code, rec.Body)
+	}
+	var resp struct {
+		Rows [][]interface{} `json:"rows"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Rows
+}
+
+func TestScopedQueries(t *testing.T) {
+	app := newAttachedTestApp(t)
+	sql := url.QueryEscape("SELECT db FROM t")
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/api/query?sql=" + sql, "main"},
+		{"/api/query?db=main_db&sql=" + sql, "main"},
+		{"/api/query?db=sales&sql=" + sql, "sales"},
+		{"/api/db/sales/query?sql=" + sql, "sales"},
+		{"/api/db/main_db/query?sql=" + sql, "main"},
+		{"/api/query?sql=" + url.QueryEscape("SELECT db FROM sales.t"), "sales"},
+	}
+	for _, tt := range tests {
+		rows := apiQueryRows(t, app, tt.target)
+		if len(rows) != 1 || rows[0][0] != tt.want {
+			t.Errorf("GET %s = %v, want [[%s]]", tt.target, rows, tt.want)
+		}
+	}
+}
+
+func TestScopedQueryPage(t *testing.T) {
+	app := newAttachedTestApp(t)
+	form := url.Values{"sql": {"SELECT db || '!' FROM t"}}
+	for _, target := range []string{"/db/sales/query", "/query?db=sales"} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		app.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s: %d", target, rec.Code)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, "sales!") || strings.Contains(body, "main!") {
+			t.Errorf("POST %s didn't query the sales database", target)
+		}
+	}
+}
+
+// TestScopedQueryIsReadOnly checks that a database's own pool can't write to
+// it, even when the main database is writable.
+func TestScopedQueryIsReadOnly(t *testing.T) {
+	sales := newTestDB(t, "sales.db", "CREATE TABLE t (db TEXT)")
+	app := newTestApp(t, "CREATE TABLE t (db TEXT)", Config{Writable: true, AttachPaths: []string{sales}})
+	db, err := app.databaseConn("sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO t VALUES ('x')"); err == nil {
+		t.Error("INSERT succeeded on the sales database")
+	}
+	if _, err := app.databaseConn("nope"); err == nil {
+		t.Error("databaseConn(\"nope\") succeeded")
+	}
+}
+
+func TestScopedQueryUnknownDatabase(t *testing.T) {
+	app := newAttachedTestApp(t)
+	for _, target := range []string{"/api/db/nope/query?sql=SELECT+1", "/api/query?db=nope&sql=SELECT+1"} {
+		rec := httptest.NewRecorder()
+		app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", target, rec.Code)
+		}
+	}
+}
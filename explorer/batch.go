@@ -0,0 +1,144 @@
+// batch.go
+package explorer
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxBatchQueries is the most queries one /api/batch request may hold.
+const maxBatchQueries = 50
+
+// batchQuery is one query of a POST /api/batch request.
+type batchQuery struct {
+	SQL       string                 `json:"sql"`
+	Params    map[string]interface{} `json:"params"`    // Bound to :name, @name or $name
+	Shape     string                 `json:"shape"`     // "arrays" (default) or "objects"
+	Transform string                 `json:"transform"` // Comma-separated RowTransformer names
+	Sparse    bool                   `json:"sparse"`    // Leave NULL values out of objects; needs the objects shape
+}
+
+// BatchResult is the outcome of one query of a batch: its columns and rows,
+// or the error that stopped it.
+type BatchResult struct {
+	Query       string          `json:"query"`
+	Columns     []string        `json:"columns,omitempty"`
+	Rows        interface{}     `json:"rows,omitempty"`
+	Truncated   bool            `json:"truncated"`
+	Error       string          `json:"error,omitempty"`
+	ErrorDetail *SQLErrorDetail `json:"error_detail,omitempty"`
+}
+
+// handleAPIBatch runs a JSON array of queries in order and returns their
+// results in the same order. The queries share the -max-rows cap, so a batch
+// returns no more rows than one query could. A query that fails gets an error
+// in its result and the others still run; only a malformed request fails as
+// a whole.
+func (a *App) handleAPIBatch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		a.respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+	var queries []batchQuery
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&queries); err != nil {
+		if isBodyTooLarge(err) {
+			a.respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		a.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Failed to parse request body: %v", err))
+		return
+	}
+	if len(queries) == 0 || len(queries) > maxBatchQueries {
+		a.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("A batch must hold 1 to %d queries", maxBatchQueries))
+		return
+	}
+
+	results := make([]BatchResult, len(queries))
+	rowsLeft := a.maxRows
+	for i, q := range queries {
+		results[i] = a.runBatchQuery(r, q, &rowsLeft)
+	}
+	a.respondWithJSON(w, http.StatusOK, results)
+}
+
+// runBatchQuery runs one query of a batch with the checks /api/query makes,
+// reading at most *rowsLeft rows, which it lowers by the rows read, unless
+// -max-rows is 0.
+func (a *App) runBatchQuery(r *http.Request, q batchQuery, rowsLeft *int) BatchResult {
+	result := BatchResult{Query: q.SQL}
+	if result.Error = a.batchQueryError(q, *rowsLeft); result.Error != "" {
+		return result
+	}
+
+	tenant, err := a.tenantArgs(r, q.SQL)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	args := append(apiQuery{Params: q.Params}.args(), tenant...)
+	ctx, done := a.trackQuery(r, q.SQL)
+	columns, rows, truncated, err := a.runCustomQuery(ctx, a.dbName, *rowsLeft, q.SQL, args...)
+	done()
+	if err != nil {
+		result.Error = fmt.Sprintf("Query execution failed: %v", err)
+		result.ErrorDetail = sqlErrorDetail(q.SQL, err)
+		return result
+	}
+	*rowsLeft -= len(rows)
+	transforms, _ := a.lookupTransformers(q.Transform) // Checked by batchQueryError
+	columns, rows = transformRows(transforms, columns, rows)
+	if rows == nil {
+		rows = [][]interface{}{}
+	}
+
+	result.Columns, result.Rows, result.Truncated = columnNames(columns), rows, truncated
+	if q.Shape == "objects" {
+		objects := rowObjects(columns, rows)
+		if q.Sparse {
+			dropNulls(objects)
+		}
+		result.Columns, result.Rows = uniqueColumnNames(columns), objects
+	}
+	return result
+}
+
+// batchQueryError returns why q can't run, or "" if it can.
+func (a *App) batchQueryError(q batchQuery, rowsLeft int) string {
+	if q.SQL == "" {
+		return "Missing 'sql' field"
+	}
+	if msg := a.queryTooLong(q.SQL, "sql"); msg != "" {
+		return msg
+	}
+	if q.Shape != "" && q.Shape != "arrays" && q.Shape != "objects" {
+		return "shape must be 'arrays' or 'objects'"
+	}
+	if q.Sparse && q.Shape != "objects" {
+		return "sparse needs \"shape\": \"objects\""
+	}
+	if _, err := a.lookupTransformers(q.Transform); err != nil {
+		return err.Error()
+	}
+	if _, ok := q.Params[tenantParam]; ok && a.tenantHeader != "" {
+		return fmt.Sprintf("params can't set %s, which comes from the %s header", tenantParam, a.tenantHeader)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(q.SQL)), "SELECT") {
+		return "Only SELECT queries are allowed."
+	}
+	if fn := a.blockedFunction(q.SQL); fn != "" {
+		return fmt.Sprintf("The function %s() is not allowed in queries.", fn)
+	}
+	if a.maxRows > 0 && rowsLeft <= 0 {
+		return fmt.Sprintf("The batch already returned the %d rows allowed by -max-rows", a.maxRows)
+	}
+	return ""
+}
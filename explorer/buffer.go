@@ -0,0 +1,100 @@
+// buffer.go
+package explorer
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// bufferResponses holds API responses of up to streamThreshold bytes in
+// memory and sends them with a Content-Length, so clients can show progress
+// and know the response is complete. Larger responses are streamed with
+// chunked encoding as soon as they outgrow the buffer, so memory use stays
+// bounded.
+func (a *App) bufferResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.streamThreshold <= 0 || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		bw := &bufferedWriter{ResponseWriter: w, limit: a.streamThreshold}
+		next.ServeHTTP(bw, r)
+		bw.finish()
+	})
+}
+
+// bufferedWriter buffers a response until it is finished, or until it grows
+// past limit bytes or is flushed, after which it writes through.
+type bufferedWriter struct {
+	http.ResponseWriter
+	limit     int
+	buf       bytes.Buffer
+	code      int  // Status passed to WriteHeader, 0 until then
+	streaming bool // The header is sent and writes go straight through
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if bw.streaming || bw.code != 0 {
+		return
+	}
+	bw.code = code
+	if bw.Header().Get("Content-Length") != "" {
+		// The handler knows the length already, e.g. of a file it copies.
+		bw.stream()
+	}
+}
+
+func (bw *bufferedWriter) Write(p []byte) (int, error) {
+	if !bw.streaming && bw.code == 0 && bw.Header().Get("Content-Length") != "" {
+		bw.stream()
+	}
+	if !bw.streaming && bw.buf.Len()+len(p) > bw.limit {
+		bw.stream()
+	}
+	if bw.streaming {
+		return bw.ResponseWriter.Write(p)
+	}
+	return bw.buf.Write(p)
+}
+
+// Flush sends what is buffered and switches to streaming, since a handler
+// that flushes wants its client to see the response as it is written.
+func (bw *bufferedWriter) Flush() {
+	bw.stream()
+	if f, ok := bw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream sends the header and the buffered body, if that hasn't happened
+// yet, and makes later writes go straight through.
+func (bw *bufferedWriter) stream() {
+	if bw.streaming {
+		return
+	}
+	bw.streaming = true
+	if bw.code == 0 {
+		bw.code = http.StatusOK
+	}
+	bw.ResponseWriter.WriteHeader(bw.code)
+	if bw.buf.Len() > 0 {
+		bw.ResponseWriter.Write(bw.buf.Bytes())
+		bw.buf.Reset()
+	}
+}
+
+// finish sends a response that fit in the buffer, with its Content-Length.
+func (bw *bufferedWriter) finish() {
+	if bw.streaming {
+		return
+	}
+	if bw.code == 0 && bw.buf.Len() == 0 {
+		return // Nothing written; net/http sends its own empty 200
+	}
+	if bw.code != http.StatusNoContent && bw.code != http.StatusNotModified {
+		bw.Header().Set("Content-Length", strconv.Itoa(bw.buf.Len()))
+	}
+	bw.stream()
+}
@@ -0,0 +1,151 @@
+// cache.go
+package explorer
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queryCache is a fixed-size LRU cache of custom query results. Entries are
+// dropped wholesale whenever the database file (or its WAL) is modified, so
+// results never outlive the data they were computed from.
+type queryCache struct {
+	mu      sync.Mutex
+	size    int
+	dbPath  string
+	modTime time.Time
+	order   *list.List // Front is most recently used
+	entries map[string]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+// cacheEntry is a single cached result set. Rows are stored after value
+// conversion, so a cache hit returns exactly what a fresh query would.
+type cacheEntry struct {
+	key       string
+	columns   []Column
+	rows      [][]interface{}
+	truncated bool
+}
+
+// newQueryCache creates a cache holding up to size result sets for the
+// database at dbPath.
+func newQueryCache(size int, dbPath string) *queryCache {
+	return &queryCache{
+		size:    size,
+		dbPath:  dbPath,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached result for key, if present and still fresh.
+func (c *queryCache) get(key string) (columns []Column, rows [][]interface{}, truncated, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidateIfChanged()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		c.hits++
+		entry := el.Value.(*cacheEntry)
+		return entry.columns, entry.rows, entry.truncated, true
+	}
+	c.misses++
+	return nil, nil, false, false
+}
+
+// put stores a result, evicting the least recently used entry if full.
+func (c *queryCache) put(key string, columns []Column, rows [][]interface{}, truncated bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		el.Value = &cacheEntry{key: key, columns: columns, rows: rows, truncated: truncated}
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, columns: columns, rows: rows, truncated: truncated})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// stats returns the hit and miss counters.
+func (c *queryCache) stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// invalidateIfChanged clears the cache if the database file or its WAL has
+// been modified since the cache was last filled. c.mu must be held.
+func (c *queryCache) invalidateIfChanged() {
+	modTime := latestModTime(c.dbPath, c.dbPath+"-wal")
+	if modTime.Equal(c.modTime) {
+		return
+	}
+	c.modTime = modTime
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+// latestModTime returns the most recent modification time among the given
+// files, ignoring any that don't exist.
+func latestModTime(paths ...string) time.Time {
+	var latest time.Time
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// queryCacheKey builds a cache key from a query, its row cap and its bound
+// parameters. Surrounding whitespace and a trailing semicolon are ignored.
+// Inner whitespace is kept as-is since it may be significant inside string
+// literals.
+func queryCacheKey(query string, maxRows int, args ...interface{}) string {
+	normalized := strings.TrimSuffix(strings.TrimSpace(query), ";")
+	return fmt.Sprintf("%s\x00%d\x00%#v", normalized, maxRows, args)
+}
+
+// schemaCache memoizes PRAGMA table_xinfo results per table. The zero value is
+// ready to use.
+type schemaCache struct {
+	mu     sync.RWMutex
+	tables map[string][]ColumnInfo
+}
+
+// get returns the cached columns of a table, if present.
+func (c *schemaCache) get(tableName string) ([]ColumnInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	columns, ok := c.tables[tableName]
+	return columns, ok
+}
+
+// put caches the columns of a table.
+func (c *schemaCache) put(tableName string, columns []ColumnInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tables == nil {
+		c.tables = make(map[string][]ColumnInfo)
+	}
+	c.tables[tableName] = columns
+}
+
+// reset forgets all cached schemas, e.g. after the database was replaced.
+func (c *schemaCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tables = nil
+}
@@ -0,0 +1,141 @@
+// cancel_test.go
+package explorer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// endlessQuery never finishes on its own: it counts an unbounded series.
+const endlessQuery = "SELECT (WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n)"
+
+// waitForQueries waits until app has no queries running, failing t if they
+// are still running after timeout.
+func waitForQueries(t *testing.T, app *App, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for len(app.runningQueries()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queries still running %s after the client left: %+v", timeout, app.runningQueries())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestQueryCancelledOnDisconnect checks that a client going away interrupts
+// the query it was waiting for.
+func TestQueryCancelledOnDisconnect(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE t (a)", Config{})
+	srv := httptest.NewServer(app.Handler())
+	defer srv.Close()
+
+	form := url.Values{"sql": {endlessQuery}}.Encode()
+	for _, target := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/query?" + form, ""},
+		{http.MethodPost, "/query", form},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, target.method, srv.URL+target.path, strings.NewReader(target.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		errc := make(chan error, 1)
+		go func() {
+			resp, err := srv.Client().Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			errc <- err
+		}()
+
+		// Wait for the query to start, then hang up.
+		deadline := time.Now().Add(5 * time.Second)
+		for len(app.runningQueries()) == 0 {
+			select {
+			case err := <-errc:
+				t.Fatalf("%s %s returned before being cancelled: %v", target.method, target.path, err)
+			default:
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s %s: query never started", target.method, target.path)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		<-errc
+		waitForQueries(t, app, 5*time.Second)
+	}
+}
+
+// TestStreamedQueryCancelled checks that cancelling the context of a
+// streamed export stops it, rather than reading the result to the end.
+func TestStreamedQueryCancelled(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE t (a)", Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- app.writeQuery(ctx, io.Discard, "csv", "Query", "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT i FROM n")
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("an endless streamed query finished without an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("streamed query kept running after its context was cancelled")
+	}
+}
+
+// TestColumnStatsCancelled checks that the full-table aggregates of the column
+// stats endpoint are listed as running and can be cancelled like any query.
+func TestColumnStatsCancelled(t *testing.T) {
+	app := newTestApp(t, `CREATE TABLE t (s TEXT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 300000)
+		INSERT INTO t SELECT hex(randomblob(8)) FROM n;`, Config{})
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/table/t/stats?column=s", nil))
+		done <- rec
+	}()
+
+	var id int64
+	deadline := time.Now().Add(5 * time.Second)
+	for id == 0 {
+		for _, q := range app.runningQueries() {
+			if strings.Contains(q.SQL, "COUNT(DISTINCT") {
+				id = q.ID
+			}
+		}
+		select {
+		case rec := <-done:
+			t.Fatalf("stats returned %d without being listed as running", rec.Code)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stats query never listed as running")
+		}
+	}
+	if !app.cancelQuery(id) {
+		t.Fatalf("cancelQuery(%d) found no query", id)
+	}
+	select {
+	case rec := <-done:
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("cancelled stats = %d %s, want 500", rec.Code, rec.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stats query kept running after being cancelled")
+	}
+	waitForQueries(t, app, time.Second)
+}
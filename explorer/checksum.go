@@ -0,0 +1,76 @@
+// checksum.go
+package explorer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// resultChecksum returns the hex SHA-256 of a result's column names and
+// rows, in order. Every value is written with its type and length, so NULL,
+// the text "NULL", 1 and "1" all hash differently, unlike in the JSON.
+func resultChecksum(columns []Column, rows [][]interface{}) string {
+	h := sha256.New()
+	for _, col := range columns {
+		writeChecksumText(h, 'c', col.Name)
+	}
+	for _, row := range rows {
+		h.Write([]byte{'\n'})
+		for _, value := range row {
+			switch v := value.(type) {
+			case nullValue, nil:
+				h.Write([]byte{'n'})
+			case int64:
+				writeChecksumText(h, 'i', strconv.FormatInt(v, 10))
+			case float64:
+				writeChecksumText(h, 'r', strconv.FormatFloat(v, 'g', -1, 64))
+			case []byte:
+				writeChecksumText(h, 'b', string(v))
+			case string:
+				writeChecksumText(h, 's', v)
+			default:
+				writeChecksumText(h, 's', fmt.Sprint(v))
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeChecksumText writes a value to h as its type tag, its length and
+// itself, so adjacent values can't run together.
+func writeChecksumText(h hash.Hash, tag byte, text string) {
+	fmt.Fprintf(h, "%c%d:%s", tag, len(text), text)
+}
+
+// checkResultETag sets the X-Result-Checksum and ETag headers of a result
+// with checksum, and reports whether r's If-None-Match already has it, in
+// which case it has answered 304 Not Modified and the caller is done. The
+// ETag is weak, since the same rows can be sent gzipped or in another format.
+func checkResultETag(w http.ResponseWriter, r *http.Request, checksum string) bool {
+	etag := `W/"` + checksum + `"`
+	w.Header().Set("X-Result-Checksum", checksum)
+	w.Header().Set("ETag", etag)
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 asks for If-None-Match, or is "*".
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
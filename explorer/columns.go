@@ -0,0 +1,206 @@
+// columns.go
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// colsCookie remembers the column selection of the table view. It is scoped
+// to each table's path, so every table has its own.
+const colsCookie = "cols"
+
+// ColumnChoice is one checkbox of the table view's column picker.
+type ColumnChoice struct {
+	Name     string
+	Selected bool
+}
+
+// selectList returns the SELECT list for the given columns, or "*" for all.
+func selectList(cols []string) string {
+	if len(cols) == 0 {
+		return "*"
+	}
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteIdent(col)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// rowidColumn is the name the rowid is selected under with ?_rowid=on. It is
+// not "rowid" itself, so it can't clash with the rowid's own name in queries.
+const rowidColumn = "_rowid"
+
+// selectList returns the SELECT list for view: its columns, led by the rowid
+// when view.Rowid is set.
+func (v tableView) selectList() string {
+	if v.Rowid {
+		return "rowid AS " + rowidColumn + ", " + selectList(v.Columns)
+	}
+	return selectList(v.Columns)
+}
+
+// freezeParam reads ?_freeze=col, the column the table view keeps in view
+// when scrolling sideways. Without it, that is the column the metadata
+// freezes, or else the primary key, or else the rowid if it is shown. An
+// empty ?_freeze= freezes no column.
+func (a *App) freezeParam(ctx context.Context, params *queryParams, tableName string) (string, error) {
+	if _, ok := params.values["_freeze"]; ok {
+		col := params.get("_freeze")
+		if col == "" || col == rowidColumn {
+			return col, nil
+		}
+		err := a.checkColumns(ctx, tableName, []string{col})
+		var unknown *unknownColumnsError
+		if errors.As(err, &unknown) {
+			params.fail("_freeze", err.Error())
+			return "", nil
+		}
+		return col, err
+	}
+	if col := a.metadata.Tables[tableName].Freeze; col != "" {
+		return col, nil
+	}
+	pk, err := a.primaryKey(ctx, tableName)
+	if pk == "" && err == nil {
+		pk = rowidColumn
+	}
+	return pk, err
+}
+
+// rowidParam reads ?_rowid=on, which exposes tableName's rowid as a column.
+// It is quietly ignored for tables without a rowid.
+func (a *App) rowidParam(ctx context.Context, params *queryParams, tableName string) (bool, error) {
+	if params.oneOf("_rowid", "off", "on", "off") != "on" {
+		return false, nil
+	}
+	return a.hasRowid(ctx, tableName)
+}
+
+// hasRowid reports whether tableName has a rowid, which WITHOUT ROWID tables
+// and views lack.
+func (a *App) hasRowid(ctx context.Context, tableName string) (bool, error) {
+	query := fmt.Sprintf("SELECT rowid FROM %s LIMIT 0", quoteIdent(tableName))
+	rows, err := a.conn().QueryContext(ctx, query)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such column: rowid") {
+			return false, nil
+		}
+		return false, err
+	}
+	rows.Close()
+	return true, nil
+}
+
+// splitColumnsParam splits ?_cols= values, which may be repeated and/or
+// comma-separated, into column names, dropping empty ones.
+func splitColumnsParam(values []string) []string {
+	var cols []string
+	for _, v := range values {
+		for _, col := range strings.Split(v, ",") {
+			if col = strings.TrimSpace(col); col != "" {
+				cols = append(cols, col)
+			}
+		}
+	}
+	return cols
+}
+
+// checkColumns returns an error naming any of cols that tableName lacks.
+func (a *App) checkColumns(ctx context.Context, tableName string, cols []string) error {
+	if len(cols) == 0 {
+		return nil
+	}
+	info, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(info))
+	for _, col := range info {
+		known[col.Name] = true
+	}
+	var unknown []string
+	for _, col := range cols {
+		if !known[col] {
+			unknown = append(unknown, col)
+		}
+	}
+	if len(unknown) > 0 {
+		return &unknownColumnsError{unknown}
+	}
+	return nil
+}
+
+// unknownColumnsError reports column names that don't exist in a table.
+type unknownColumnsError struct {
+	names []string
+}
+
+func (e *unknownColumnsError) Error() string {
+	return fmt.Sprintf("Unknown columns: %s", strings.Join(e.names, ", "))
+}
+
+// viewColumns returns the column selection for the HTML table view: from
+// ?_cols= if given, in which case it is also saved in a cookie for the table,
+// and from that cookie otherwise. An empty ?_cols= clears the selection.
+// Selections from the cookie that no longer match the table are ignored.
+func (a *App) viewColumns(w http.ResponseWriter, r *http.Request, tableName string) ([]string, error) {
+	cookiePath := "/table/" + url.PathEscape(tableName)
+	values, ok := r.URL.Query()["_cols"]
+	if !ok {
+		c, err := r.Cookie(colsCookie)
+		if err != nil {
+			return nil, nil
+		}
+		value, _ := url.QueryUnescape(c.Value)
+		cols := splitColumnsParam([]string{value})
+		if a.checkColumns(r.Context(), tableName, cols) != nil {
+			return nil, nil
+		}
+		return cols, nil
+	}
+
+	cols := splitColumnsParam(values)
+	if err := a.checkColumns(r.Context(), tableName, cols); err != nil {
+		return nil, err
+	}
+	cookie := &http.Cookie{
+		Name:     colsCookie,
+		Value:    url.QueryEscape(strings.Join(cols, ",")),
+		Path:     cookiePath,
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if len(cols) == 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+	return cols, nil
+}
+
+// columnChoices lists every column of tableName for the column picker,
+// marking the selected ones (all of them if selected is empty).
+func (a *App) columnChoices(ctx context.Context, tableName string, selected []string) []ColumnChoice {
+	info, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return nil
+	}
+	chosen := make(map[string]bool, len(selected))
+	for _, col := range selected {
+		chosen[col] = true
+	}
+	choices := make([]ColumnChoice, 0, len(info))
+	for _, col := range info {
+		if col.Hidden == columnHidden {
+			continue // Not part of SELECT *, so not offered either
+		}
+		choices = append(choices, ColumnChoice{Name: col.Name, Selected: len(selected) == 0 || chosen[col.Name]})
+	}
+	return choices
+}
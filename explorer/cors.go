@@ -0,0 +1,86 @@
+// cors.go
+package explorer
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// corsMethods are the methods announced in preflight responses.
+const corsMethods = "GET, POST"
+
+// validateCORS checks the CORS options of cfg. Browsers reject credentialed
+// responses that allow any origin, so that combination is refused up front.
+func validateCORS(cfg Config) error {
+	for _, origin := range cfg.CORSOrigins {
+		if origin == "*" && cfg.CORSCredentials {
+			return fmt.Errorf("CORS credentials cannot be combined with the * origin; list the allowed origins instead")
+		}
+	}
+	if cfg.CORSMaxAge < 0 {
+		return fmt.Errorf("invalid CORS max age %d: must not be negative", cfg.CORSMaxAge)
+	}
+	return nil
+}
+
+// withCORS adds CORS headers to API responses for allowed origins and answers
+// preflight requests itself. Requests outside /api/ pass through untouched.
+func (a *App) withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Every API response depends on Origin, including those to requests
+		// without one, so that a shared cache doesn't hand a response lacking
+		// Access-Control-Allow-Origin to a cross-origin browser.
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowOrigin := a.corsAllowOrigin(origin)
+		if allowOrigin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", allowOrigin)
+		if a.corsCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Preflight request.
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", corsMethods)
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		if a.corsMaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(a.corsMaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// corsAllowOrigin returns the Access-Control-Allow-Origin value for a request
+// from origin, or "" if the origin isn't allowed.
+func (a *App) corsAllowOrigin(origin string) string {
+	for _, allowed := range a.corsOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
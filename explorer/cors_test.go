@@ -0,0 +1,46 @@
+// cors_test.go
+package explorer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCORSVary checks that every API response varies on Origin, whether or
+// not the request had one, while other routes don't.
+func TestCORSVary(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE t (a);", Config{CORSOrigins: []string{"https://app.example.com"}, AllowDownload: true})
+	tests := []struct {
+		name, target, origin string
+		wantVary             bool
+		wantAllow            string
+	}{
+		{"no origin", "/api/tables", "", true, ""},
+		{"allowed origin", "/api/tables", "https://app.example.com", true, "https://app.example.com"},
+		{"other origin", "/api/tables", "https://evil.example.com", true, ""},
+		{"download keeps Accept-Encoding", "/api/download.db", "", true, ""},
+		{"HTML page", "/table/t", "https://app.example.com", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			app.Handler().ServeHTTP(rec, req)
+			vary := strings.Join(rec.Header().Values("Vary"), ", ")
+			if strings.Contains(vary, "Origin") != tt.wantVary {
+				t.Errorf("Vary = %q, want Origin: %v", vary, tt.wantVary)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+			if strings.HasPrefix(tt.target, "/api/download") && !strings.Contains(vary, "Accept-Encoding") {
+				t.Errorf("Vary = %q, want Accept-Encoding too", vary)
+			}
+		})
+	}
+}
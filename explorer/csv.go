@@ -0,0 +1,129 @@
+// csv.go
+package explorer
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// csvDelimiters are the field separators ?_delimiter= allows, by the name
+// used for them there.
+var csvDelimiters = map[string]byte{",": ',', ";": ';', "tab": '\t', "|": '|'}
+
+// csvDelimiterNames lists the keys of csvDelimiters in the order error
+// messages give them.
+var csvDelimiterNames = []string{",", ";", "tab", "|"}
+
+// csvOptions is the CSV dialect a request asks for.
+type csvOptions struct {
+	Null      string // Text written for NULL, empty by default
+	Delimiter byte   // Field separator, a comma if zero
+	CRLF      bool   // End lines with \r\n instead of \n
+	BOM       bool   // Start with a UTF-8 byte order mark, for Excel
+}
+
+// csvParams reads the CSV options of an API GET request: _null, _delimiter,
+// _crlf and _bom.
+func csvParams(params *queryParams) csvOptions {
+	return csvOptions{
+		Null:      params.get("_null"),
+		Delimiter: csvDelimiters[params.oneOf("_delimiter", ",", csvDelimiterNames...)],
+		CRLF:      params.oneOf("_crlf", "off", "on", "off") == "on",
+		BOM:       params.oneOf("_bom", "off", "on", "off") == "on",
+	}
+}
+
+// respondWithCSV writes rows as CSV; see formatCSV.
+func (a *App) respondWithCSV(w http.ResponseWriter, columns []Column, rows [][]interface{}, opts csvOptions) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(formatCSV(columns, rows, opts)))
+}
+
+// formatCSV formats rows as CSV, with a header line of column names. NULL
+// values are written as opts.Null, unquoted, and empty strings as "", so the
+// two stay distinct: by default NULL is an empty field.
+func formatCSV(columns []Column, rows [][]interface{}, opts csvOptions) string {
+	var b strings.Builder
+	b.WriteString(csvHeader(columns, opts))
+	for _, row := range rows {
+		b.WriteString(csvRow(row, opts))
+	}
+	return b.String()
+}
+
+// csvHeader returns the first line of CSV written with opts: the byte order
+// mark, if asked for, and the column names.
+func csvHeader(columns []Column, opts csvOptions) string {
+	delim, newline := opts.dialect()
+	var b strings.Builder
+	if opts.BOM {
+		b.WriteString("\uFEFF")
+	}
+	for i, col := range columns {
+		if i > 0 {
+			b.WriteByte(delim)
+		}
+		writeCSVField(&b, col.Name, delim)
+	}
+	b.WriteString(newline)
+	return b.String()
+}
+
+// csvRow returns one row as a line of CSV written with opts.
+func csvRow(row []interface{}, opts csvOptions) string {
+	delim, newline := opts.dialect()
+	var b strings.Builder
+	for i, value := range row {
+		if i > 0 {
+			b.WriteByte(delim)
+		}
+		if _, ok := value.(nullValue); ok {
+			if opts.Null != "" {
+				writeCSVField(&b, opts.Null, delim)
+			}
+			continue
+		}
+		writeCSVField(&b, csvText(value), delim)
+	}
+	b.WriteString(newline)
+	return b.String()
+}
+
+// dialect returns the field separator and line ending of opts.
+func (opts csvOptions) dialect() (delim byte, newline string) {
+	delim, newline = opts.Delimiter, "\n"
+	if delim == 0 {
+		delim = ','
+	}
+	if opts.CRLF {
+		newline = "\r\n"
+	}
+	return delim, newline
+}
+
+// writeCSVField writes field, quoted if it is empty or holds delim, a quote,
+// a line break or a leading space, with quotes doubled.
+func writeCSVField(b *strings.Builder, field string, delim byte) {
+	if field != "" && !strings.ContainsAny(field, "\"\r\n") && strings.IndexByte(field, delim) < 0 && field[0] != ' ' {
+		b.WriteString(field)
+		return
+	}
+	b.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`)
+}
+
+// csvText returns a non-NULL value as CSV text.
+func csvText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,65 @@
+// deadline.go
+package explorer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// connKey is the context key ConnContext stores the connection under.
+type connKey struct{}
+
+// ConnContext is an http.Server ConnContext hook that lets the handler set
+// the write deadline of each request's connection, see withWriteDeadline.
+// Servers using it should set WriteTimeout to Config.ExportTimeout, the
+// longest a response may take: the handler replaces that deadline for
+// HTTP/1 requests, while HTTP/2 streams, which it can't reach, keep it.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// withWriteDeadline gives each request until writeTimeout to write its
+// response, or until exportTimeout for exports and streams (see isExport),
+// which can take much longer; a zero timeout means no deadline. It sets the
+// deadline on the connection, as http.ResponseController does from Go 1.20,
+// so it only works on servers using ConnContext, and not for HTTP/2, whose
+// requests share a connection and are left to the server's WriteTimeout.
+func (a *App) withWriteDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, ok := r.Context().Value(connKey{}).(net.Conn)
+		if !ok || r.ProtoMajor >= 2 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout := a.writeTimeout
+		if isExport(r) {
+			timeout = a.exportTimeout
+		}
+		deadline := time.Time{}
+		if timeout > 0 {
+			deadline = time.Now().Add(timeout)
+		}
+		conn.SetWriteDeadline(deadline)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isExport reports whether r asks for an export or stream, which may take
+// longer to send than other responses: a database download, a table tail, or
+// an API response in another format than JSON, like CSV.
+func isExport(r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case path == "/api/download.db":
+		return true
+	case strings.HasPrefix(path, "/table/") && strings.HasSuffix(path, "/tail"):
+		return true
+	case strings.HasPrefix(path, "/api/"):
+		format := r.URL.Query().Get("_format")
+		return format != "" && format != "json"
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+// deadline_test.go
+package explorer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestWriteTimeoutHTTP2 checks that a stream served over HTTP/2, which
+// withWriteDeadline can't reach, is still ended by the server's WriteTimeout.
+func TestWriteTimeoutHTTP2(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE log (msg TEXT)", Config{
+		WriteTimeout:  time.Second,
+		ExportTimeout: 300 * time.Millisecond,
+		TailInterval:  50 * time.Millisecond,
+	})
+	srv := httptest.NewUnstartedServer(app.Handler())
+	srv.EnableHTTP2 = true
+	srv.Config.ConnContext = ConnContext
+	srv.Config.WriteTimeout = app.exportTimeout
+	srv.StartTLS()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/table/log/tail", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("got %s, want HTTP/2", resp.Proto)
+	}
+	start := time.Now()
+	_, err = io.Copy(io.Discard, resp.Body)
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("tail stream was still open after %s", time.Since(start))
+	}
+}
+
+// TestWriteDeadlineHTTP1 checks that over HTTP/1 the handler replaces the
+// server's WriteTimeout with the request's own: the export timeout for the
+// tail stream.
+func TestWriteDeadlineHTTP1(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE log (msg TEXT)", Config{
+		WriteTimeout:  time.Minute,
+		ExportTimeout: 300 * time.Millisecond,
+		TailInterval:  50 * time.Millisecond,
+	})
+	srv := httptest.NewUnstartedServer(app.Handler())
+	srv.Config.ConnContext = ConnContext
+	srv.Config.WriteTimeout = time.Minute
+	srv.Start()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/table/log/tail", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	start := time.Now()
+	_, err = io.Copy(io.Discard, resp.Body)
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("tail stream was still open after %s", time.Since(start))
+	}
+}
@@ -0,0 +1,65 @@
+// download.go
+package explorer
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// handleAPIDownload sends a consistent snapshot of the main database file,
+// gzip-compressed if the client accepts it. The snapshot is taken with VACUUM
+// INTO, so a file being written to concurrently is never sent half-updated.
+func (a *App) handleAPIDownload(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !a.allowDownload {
+		a.respondWithError(w, http.StatusForbidden, "Database downloads require the server to run with -allow-db-download")
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "godatasette-download-")
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to create snapshot", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, "snapshot.db")
+	if _, err := a.conn().ExecContext(r.Context(), "VACUUM main INTO ?", snapshot); err != nil {
+		a.respondWithInternalError(w, r, "Failed to create snapshot", err)
+		return
+	}
+
+	f, err := os.Open(snapshot)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to open snapshot", err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	filename := a.displayName()
+	if filepath.Ext(filename) == "" {
+		filename += ".db"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		if info, err := f.Stat(); err == nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		}
+		io.Copy(w, f)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+	io.Copy(gz, f)
+}
@@ -0,0 +1,96 @@
+// driver.go
+package explorer
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLite drivers Config.Driver can name. Both take "file:" URIs, but spell
+// their own connection options differently.
+const (
+	DriverMattn   = "sqlite3" // github.com/mattn/go-sqlite3 (cgo), compiled in by default
+	DriverModernc = "sqlite"  // modernc.org/sqlite (pure Go), compiled in with -tags modernc
+)
+
+// validateDriver checks that name is a supported SQLite driver registered
+// with database/sql; an empty name means DefaultDriver.
+func validateDriver(name string) (string, error) {
+	switch name {
+	case "":
+		return DefaultDriver, nil
+	case DriverMattn, DriverModernc:
+	default:
+		return "", fmt.Errorf("invalid driver %q: must be %q or %q", name, DriverMattn, DriverModernc)
+	}
+	if _, err := lookupDriver(name); err != nil {
+		return "", fmt.Errorf("driver %q is not compiled in: %w", name, err)
+	}
+	return name, nil
+}
+
+// lookupDriver returns the database/sql driver registered as name.
+func lookupDriver(name string) (driver.Driver, error) {
+	db, err := sql.Open(name, "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return db.Driver(), nil
+}
+
+// driverDSN returns the connection string for the database at dbPath, read
+// only unless writable is set, with params added as URI parameters. mode is
+// a SQLite URI parameter, so it reads the same for every driver; the driver's
+// own options in params have to be spelled for driverName, see
+// setBusyTimeout.
+func driverDSN(driverName, dbPath string, writable bool, params url.Values) string {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("mode", "ro")
+	if writable {
+		query.Set("mode", "rw")
+	}
+	return fmt.Sprintf("file:%s?%s", dbPath, query.Encode())
+}
+
+// defaultBusyTimeout is how long queries wait for a lock held by another
+// connection, e.g. a writing process, when Config.BusyTimeout is zero.
+const defaultBusyTimeout = 5 * time.Second
+
+// setBusyTimeout adds the busy timeout option of driverName to params, unless
+// they set one already. SQLite then retries a locked database for that long
+// instead of failing at once with "database is locked". go-sqlite3 takes
+// _busy_timeout (or its alias _timeout) in milliseconds, while modernc's
+// driver runs any pragma given as _pragma=busy_timeout(ms).
+func setBusyTimeout(driverName string, params url.Values, timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid busy timeout %s: must not be negative", timeout)
+	}
+	if timeout == 0 {
+		timeout = defaultBusyTimeout
+	}
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
+	switch driverName {
+	case DriverModernc:
+		for _, pragma := range params["_pragma"] {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(pragma)), "busy_timeout") {
+				return nil
+			}
+		}
+		params.Add("_pragma", "busy_timeout("+ms+")")
+	default:
+		if params.Has("_busy_timeout") || params.Has("_timeout") {
+			return nil
+		}
+		params.Set("_busy_timeout", ms)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// driver_test.go
+package explorer
+
+import (
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDriverDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		driver   string
+		writable bool
+		params   url.Values
+		want     string
+	}{
+		{"mattn read-only", DriverMattn, false, url.Values{"_busy_timeout": {"5000"}}, "file:/data/app.db?_busy_timeout=5000&mode=ro"},
+		{"mattn writable", DriverMattn, true, url.Values{"_busy_timeout": {"5000"}}, "file:/data/app.db?_busy_timeout=5000&mode=rw"},
+		{"modernc read-only", DriverModernc, false, url.Values{"_pragma": {"busy_timeout(5000)"}}, "file:/data/app.db?_pragma=busy_timeout%285000%29&mode=ro"},
+		{"modernc writable", DriverModernc, true, url.Values{"_pragma": {"foreign_keys(1)", "busy_timeout(5000)"}}, "file:/data/app.db?_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&mode=rw"},
+		{"mode is overridden", DriverMattn, false, url.Values{"mode": {"rwc"}}, "file:/data/app.db?mode=ro"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := driverDSN(tt.driver, "/data/app.db", tt.writable, tt.params)
+			if got != tt.want {
+				t.Errorf("driverDSN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDriverDSNLeavesParamsAlone(t *testing.T) {
+	params := url.Values{"cache": {"shared"}}
+	driverDSN(DriverMattn, "app.db", true, params)
+	if params.Has("mode") {
+		t.Errorf("driverDSN() added mode to the caller's params: %v", params)
+	}
+}
+
+func TestValidateDriver(t *testing.T) {
+	if got, err := validateDriver(""); err != nil || got != DefaultDriver {
+		t.Errorf("validateDriver(\"\") = %q, %v, want %q", got, err, DefaultDriver)
+	}
+	if got, err := validateDriver(DefaultDriver); err != nil || got != DefaultDriver {
+		t.Errorf("validateDriver(%q) = %q, %v", DefaultDriver, got, err)
+	}
+	if _, err := validateDriver("postgres"); err == nil || !strings.Contains(err.Error(), "invalid driver") {
+		t.Errorf("validateDriver(\"postgres\") error = %v, want invalid driver", err)
+	}
+	other := DriverModernc
+	if DefaultDriver == DriverModernc {
+		other = DriverMattn
+	}
+	if _, err := validateDriver(other); err == nil || !strings.Contains(err.Error(), "not compiled in") {
+		t.Errorf("validateDriver(%q) error = %v, want not compiled in", other, err)
+	}
+}
+
+// TestOpenDBWithDefaultDriver opens a database through the connection string
+// of whichever driver this build compiles in, checking the driver honours its
+// mode and busy timeout spellings.
+func TestOpenDBWithDefaultDriver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	rw, err := sql.Open(DefaultDriver, "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.Exec("CREATE TABLE t (a)"); err != nil {
+		t.Fatal(err)
+	}
+	rw.Close()
+
+	params := url.Values{}
+	if err := setBusyTimeout(DefaultDriver, params, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDB(DefaultDriver, path, false, params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var timeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 1500 {
+		t.Errorf("busy_timeout = %d, want 1500", timeout)
+	}
+	if _, err := db.Exec("INSERT INTO t VALUES (1)"); err == nil {
+		t.Error("INSERT succeeded on a read-only connection")
+	}
+}
+
+func TestSetBusyTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  string
+		params  url.Values
+		timeout time.Duration
+		want    url.Values
+	}{
+		{"mattn default", DriverMattn, url.Values{}, 0, url.Values{"_busy_timeout": {"5000"}}},
+		{"mattn custom", DriverMattn, url.Values{}, 1500 * time.Millisecond, url.Values{"_busy_timeout": {"1500"}}},
+		{"mattn sub-millisecond", DriverMattn, url.Values{}, 1500 * time.Microsecond, url.Values{"_busy_timeout": {"1"}}},
+		{"mattn keeps _busy_timeout", DriverMattn, url.Values{"_busy_timeout": {"100"}}, time.Second, url.Values{"_busy_timeout": {"100"}}},
+		{"mattn keeps _timeout", DriverMattn, url.Values{"_timeout": {"100"}}, time.Second, url.Values{"_timeout": {"100"}}},
+		{"modernc default", DriverModernc, url.Values{}, 0, url.Values{"_pragma": {"busy_timeout(5000)"}}},
+		{"modernc custom", DriverModernc, url.Values{}, 2 * time.Second, url.Values{"_pragma": {"busy_timeout(2000)"}}},
+		{"modernc adds to other pragmas", DriverModernc, url.Values{"_pragma": {"foreign_keys(1)"}}, 0, url.Values{"_pragma": {"foreign_keys(1)", "busy_timeout(5000)"}}},
+		{"modernc keeps busy_timeout", DriverModernc, url.Values{"_pragma": {" BUSY_TIMEOUT(100)"}}, time.Second, url.Values{"_pragma": {" BUSY_TIMEOUT(100)"}}},
+		{"modernc ignores mattn spelling", DriverModernc, url.Values{"_busy_timeout": {"100"}}, 0, url.Values{"_busy_timeout": {"100"}, "_pragma": {"busy_timeout(5000)"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := setBusyTimeout(tt.driver, tt.params, tt.timeout); err != nil {
+				t.Fatal(err)
+			}
+			if tt.params.Encode() != tt.want.Encode() {
+				t.Errorf("params = %v, want %v", tt.params, tt.want)
+			}
+		})
+	}
+	if err := setBusyTimeout(DriverMattn, url.Values{}, -time.Second); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("setBusyTimeout(-1s) error = %v, want must not be negative", err)
+	}
+}
+
+func TestParseDSNParams(t *testing.T) {
+	params, err := parseDSNParams("immutable=1&cache=shared&_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := driverDSN(DriverModernc, "app.db", false, params); got != "file:app.db?_pragma=foreign_keys%281%29&cache=shared&immutable=1&mode=ro" {
+		t.Errorf("driverDSN() = %q", got)
+	}
+	for raw, want := range map[string]string{
+		"mode=rw":   "mode is set by -writable",
+		"bogus=1":   `unknown parameter "bogus"`,
+		"cache=%zz": "invalid DSN parameters",
+	} {
+		if _, err := parseDSNParams(raw); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseDSNParams(%q) error = %v, want %q", raw, err, want)
+		}
+	}
+}
+
+// TestNewAppBusyTimeout checks the busy timeout NewApp's connections get from
+// -busy-timeout and -dsn-params, spelled for whichever driver is compiled in.
+func TestNewAppBusyTimeout(t *testing.T) {
+	own := "_busy_timeout=250"
+	if DefaultDriver == DriverModernc {
+		own = "_pragma=busy_timeout(250)"
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+		want int
+	}{
+		{"default", Config{}, 5000},
+		{"flag", Config{BusyTimeout: 750 * time.Millisecond}, 750},
+		{"DSN parameter wins", Config{BusyTimeout: 750 * time.Millisecond, DSNParams: own}, 250},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t, "CREATE TABLE t (a);", tt.cfg)
+			var timeout int
+			if err := app.conn().QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+				t.Fatal(err)
+			}
+			if timeout != tt.want {
+				t.Errorf("busy_timeout = %d, want %d", timeout, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// exec.go
+package explorer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExecQuery runs query once and writes its result to w in format, one of
+// "json", "yaml", "csv" or "xlsx", for running godatasette as a command-line
+// query runner. The query is held to the same rules as custom queries on the
+// server: it must be a SELECT, not too long, and call no blocked function.
+// Unlike /api/query, all rows are written, whatever -max-rows is.
+func (a *App) ExecQuery(ctx context.Context, w io.Writer, query, format string) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("the query is empty")
+	}
+	if msg := a.queryTooLong(query, "query"); msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
+		return fmt.Errorf("only SELECT queries are allowed")
+	}
+	if fn := a.blockedFunction(query); fn != "" {
+		return fmt.Errorf("the function %s() is not allowed in queries", fn)
+	}
+
+	if err := a.writeQuery(ctx, w, format, "Query", query); err != nil {
+		return fmt.Errorf("query execution failed: %v", err)
+	}
+	return nil
+}
+
+// writeQuery runs query and writes its rows to w in format as they are read,
+// so results of any size can be written without holding them in memory. If
+// reading fails partway, what was written so far is left in w.
+func (a *App) writeQuery(ctx context.Context, w io.Writer, format, sheet, query string) (err error) {
+	start := time.Now()
+	defer func() { a.logQuery(query, nil, time.Since(start)) }()
+	ctx, sp := a.traceQuery(ctx, query)
+	defer func() {
+		sp.fail(err)
+		sp.finish()
+	}()
+
+	rows, err := a.conn().QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rowColumns(rows)
+	if err != nil {
+		return err
+	}
+	rw, err := newResultWriter(w, format, sheet, columns)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values, err := a.scanRow(rows, columns)
+		if err != nil {
+			return err
+		}
+		if err := rw.writeRow(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rw.close()
+}
+
+// checkFormat reports whether format is one writeResult can write.
+func checkFormat(format string) error {
+	switch format {
+	case "json", "yaml", "csv", "xlsx":
+		return nil
+	}
+	return fmt.Errorf("format must be 'json', 'yaml', 'csv' or 'xlsx'")
+}
+
+// writeResult writes a result to w in format: the JSON object of column
+// names and rows, the YAML and CSV of the API, or a workbook whose sheet is
+// named sheet.
+func writeResult(w io.Writer, format, sheet string, columns []Column, rows [][]interface{}) error {
+	rw, err := newResultWriter(w, format, sheet, columns)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := rw.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return rw.close()
+}
+
+// resultWriter writes the output of writeResult a row at a time.
+type resultWriter interface {
+	writeRow(row []interface{}) error
+	close() error // Writes the end of the output; the writer can't be used after
+}
+
+// newResultWriter returns a resultWriter for format that writes to w, having
+// written the start of the output, like the header line of a CSV file.
+func newResultWriter(w io.Writer, format, sheet string, columns []Column) (resultWriter, error) {
+	if format == "xlsx" {
+		return newXLSXWriter(w, sheet, columns)
+	}
+	tw := &textResultWriter{w: bufio.NewWriter(w), format: format, names: uniqueColumnNames(columns)}
+	switch format {
+	case "csv":
+		tw.w.WriteString(csvHeader(columns, csvOptions{}))
+	case "json":
+		names, err := json.Marshal(columnNames(columns))
+		if err != nil {
+			return nil, err
+		}
+		tw.w.WriteString(`{"columns":` + string(names) + `,"rows":[`)
+	}
+	return tw, nil
+}
+
+// textResultWriter writes the text formats of writeResult: JSON, YAML and
+// CSV. Write errors are sticky in the bufio.Writer and reported by close.
+type textResultWriter struct {
+	w      *bufio.Writer
+	format string
+	names  []string // YAML keys of the columns
+	rows   int      // Rows written so far
+}
+
+func (tw *textResultWriter) writeRow(row []interface{}) error {
+	switch tw.format {
+	case "csv":
+		tw.w.WriteString(csvRow(row, csvOptions{}))
+	case "yaml":
+		tw.w.WriteString(yamlRow(tw.names, row))
+	default:
+		body, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if tw.rows > 0 {
+			tw.w.WriteByte(',')
+		}
+		tw.w.Write(body)
+	}
+	tw.rows++
+	return nil
+}
+
+func (tw *textResultWriter) close() error {
+	switch tw.format {
+	case "yaml":
+		if tw.rows == 0 || len(tw.names) == 0 {
+			tw.w.WriteString("[]\n")
+		}
+	case "json":
+		tw.w.WriteString("]}\n")
+	}
+	return tw.w.Flush()
+}
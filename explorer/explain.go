@@ -0,0 +1,444 @@
+// explain.go
+package explorer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// PlanStep is one row of EXPLAIN QUERY PLAN. Steps form a tree through
+// Parent, which is 0 for top-level steps.
+type PlanStep struct {
+	ID     int    `json:"id"`
+	Parent int    `json:"parent"`
+	Detail string `json:"detail"`
+}
+
+// IndexSuggestion is an index that might let SQLite search a table the plan
+// scans in full. It is advice only; nothing is created.
+type IndexSuggestion struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"` // Equality columns first, then at most one range column
+	SQL     string   `json:"sql"`     // CREATE INDEX statement
+	Reason  string   `json:"reason"`
+}
+
+// scanStepRe matches plan steps that read a whole table without an index:
+// "SCAN users", "SCAN users AS u", or "SCAN TABLE users" from SQLite before
+// 3.36. Steps that scan an index or a virtual table have more after the name.
+var scanStepRe = regexp.MustCompile(`^SCAN (?:TABLE )?("[^"]+"|\S+)(?: AS (\S+))?$`)
+
+// handleAPIExplain returns the query plan of ?sql=, and with ?suggest=1
+// indexes that might avoid full table scans.
+func (a *App) handleAPIExplain(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	params := newQueryParams(r)
+	query := params.get("sql")
+	suggest := params.oneOf("suggest", "0", "0", "1") == "1"
+	if query == "" {
+		params.fail("sql", "Missing 'sql' query parameter")
+	} else if msg := a.queryTooLong(query, "sql"); msg != "" {
+		params.fail("sql", msg)
+	}
+	if err := params.err(); err != nil {
+		a.respondWithParamErrors(w, err)
+		return
+	}
+	if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
+		a.respondWithError(w, http.StatusForbidden, "Only SELECT queries are allowed.")
+		return
+	}
+	if fn := a.blockedFunction(query); fn != "" {
+		a.respondWithError(w, http.StatusForbidden, fmt.Sprintf("The function %s() is not allowed in queries.", fn))
+		return
+	}
+
+	plan, err := a.queryPlan(r.Context(), query)
+	if err != nil {
+		response := map[string]interface{}{"error": fmt.Sprintf("Query planning failed: %v", err)}
+		if detail := sqlErrorDetail(query, err); detail != nil {
+			response["error_detail"] = detail
+		}
+		a.respondWithJSON(w, http.StatusBadRequest, response)
+		return
+	}
+	response := map[string]interface{}{"query": query, "plan": plan}
+	if suggest {
+		suggestions, err := a.suggestIndexes(r.Context(), query, plan)
+		if err != nil {
+			a.respondWithInternalError(w, r, "Failed to read table indexes", err)
+			return
+		}
+		response["suggestions"] = suggestions
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+// queryPlan runs EXPLAIN QUERY PLAN for query, with its parameters bound to
+// NULL, since the plan doesn't depend on their values.
+func (a *App) queryPlan(ctx context.Context, query string) ([]PlanStep, error) {
+	var args []interface{}
+	for _, tok := range sqlTokens(query) {
+		if tok.text == "?" {
+			args = append(args, nil)
+		}
+	}
+	for _, name := range placeholderNames(query) {
+		args = append(args, sql.Named(name, nil))
+	}
+	rows, err := a.conn().QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	plan := []PlanStep{}
+	for rows.Next() {
+		var step PlanStep
+		var unused int
+		if err := rows.Scan(&step.ID, &step.Parent, &unused, &step.Detail); err != nil {
+			return nil, err
+		}
+		plan = append(plan, step)
+	}
+	return plan, rows.Err()
+}
+
+// suggestIndexes suggests an index for each table plan scans in full that
+// query filters on columns no index leads with. It is a heuristic: columns
+// count as filtered when a comparison follows or precedes them in a WHERE or
+// ON clause, see filterColumns.
+func (a *App) suggestIndexes(ctx context.Context, query string, plan []PlanStep) ([]IndexSuggestion, error) {
+	tokens := sqlTokens(query)
+	filters := filterColumns(tokens)
+	aliases := tableAliases(tokens)
+	suggestions := []IndexSuggestion{}
+	seen := map[string]bool{}
+	for _, step := range plan {
+		m := scanStepRe.FindStringSubmatch(step.Detail)
+		if m == nil {
+			continue
+		}
+		table, alias := strings.Trim(m[1], `"`), m[2]
+		if aliased, ok := aliases[strings.ToLower(table)]; ok {
+			// Newer SQLite versions name aliased tables by their alias.
+			table, alias = aliased, table
+		}
+		if seen[table] {
+			continue
+		}
+		seen[table] = true
+
+		info, err := a.tableInfo(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(info) == 0 {
+			continue // A subquery or CTE rather than a table
+		}
+		indexed, err := a.leadingIndexColumns(ctx, table, info)
+		if err != nil {
+			return nil, err
+		}
+		columns := make(map[string]string, len(info)) // Lowercased name to name
+		for _, col := range info {
+			columns[strings.ToLower(col.Name)] = col.Name
+		}
+
+		var eq []string
+		var rangeCol string
+		added := map[string]bool{}
+		for _, f := range filters {
+			if f.qualifier != "" && !strings.EqualFold(f.qualifier, table) && !strings.EqualFold(f.qualifier, alias) {
+				continue
+			}
+			name, ok := columns[strings.ToLower(f.column)]
+			if !ok || indexed[strings.ToLower(name)] || added[name] {
+				continue
+			}
+			if f.equality {
+				eq = append(eq, name)
+				added[name] = true
+			} else if rangeCol == "" {
+				rangeCol = name
+			}
+		}
+		cols := eq
+		if rangeCol != "" && !added[rangeCol] {
+			cols = append(cols, rangeCol)
+		}
+		if len(cols) == 0 {
+			continue
+		}
+		quoted := make([]string, len(cols))
+		for i, col := range cols {
+			quoted[i] = quoteIdent(col)
+		}
+		suggestions = append(suggestions, IndexSuggestion{
+			Table:   table,
+			Columns: cols,
+			SQL:     fmt.Sprintf("CREATE INDEX %s ON %s (%s);", quoteIdent("idx_"+table+"_"+strings.Join(cols, "_")), quoteIdent(table), strings.Join(quoted, ", ")),
+			Reason:  fmt.Sprintf("%s reads every row of %s to filter on %s", step.Detail, table, strings.Join(cols, ", ")),
+		})
+	}
+	return suggestions, nil
+}
+
+// leadingIndexColumns returns the lowercased columns of tableName that an
+// index can search by: the first column of each index, and the INTEGER
+// PRIMARY KEY, which is the rowid.
+func (a *App) leadingIndexColumns(ctx context.Context, tableName string, info []ColumnInfo) (map[string]bool, error) {
+	indexed := map[string]bool{"rowid": true, "oid": true, "_rowid_": true}
+	pkCount := 0
+	for _, col := range info {
+		if col.PK > 0 {
+			pkCount++
+		}
+	}
+	for _, col := range info {
+		if col.PK > 0 && pkCount == 1 && strings.EqualFold(col.Type, "INTEGER") {
+			indexed[strings.ToLower(col.Name)] = true
+		}
+	}
+
+	rows, err := a.conn().QueryContext(ctx, fmt.Sprintf("PRAGMA index_list(%s)", quoteIdent(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []string
+	for rows.Next() {
+		var seq, unique, partial int
+		var name, origin string
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if partial == 0 {
+			indexes = append(indexes, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, index := range indexes {
+		var seqno, cid int
+		var name *string // NULL for expressions
+		query := fmt.Sprintf("PRAGMA index_info(%s)", quoteIdent(index))
+		err := a.conn().QueryRowContext(ctx, query).Scan(&seqno, &cid, &name)
+		if err != nil {
+			return nil, err
+		}
+		if name != nil {
+			indexed[strings.ToLower(*name)] = true
+		}
+	}
+	return indexed, nil
+}
+
+// filterColumn is a column a query compares in a WHERE or ON clause.
+type filterColumn struct {
+	qualifier string // Table name or alias before the column, if any
+	column    string
+	equality  bool // Compared with =, ==, IS or IN rather than a range
+}
+
+// comparisonOps are the operators filterColumns looks for, by whether they
+// test equality.
+var comparisonOps = map[string]bool{
+	"=": true, "==": true, "IS": true, "IN": true,
+	"<": false, ">": false, "<=": false, ">=": false, "BETWEEN": false,
+}
+
+// filterEnds are the keywords that end a WHERE or ON clause.
+var filterEnds = map[string]bool{
+	"GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true, "WINDOW": true,
+	"SELECT": true, "FROM": true, "JOIN": true, "UNION": true, "EXCEPT": true, "INTERSECT": true,
+}
+
+// filterColumns returns the column references of query next to a comparison
+// in a WHERE or ON clause, like price in "WHERE price < 10" or
+// "WHERE 10 > o.price", from the tokens of a query. Like calledFunctions it
+// is a heuristic rather than a parser, so the names are only candidates to
+// check against a table's columns.
+func filterColumns(tokens []sqlToken) []filterColumn {
+	var filters []filterColumn
+	inFilter := false
+	for i, tok := range tokens {
+		upper := strings.ToUpper(tok.text)
+		if !tok.quoted {
+			switch {
+			case upper == "WHERE" || upper == "ON":
+				inFilter = true
+				continue
+			case filterEnds[upper]:
+				inFilter = false
+				continue
+			}
+		}
+		if !inFilter || tok.quoted || tok.name {
+			continue
+		}
+		equality, ok := comparisonOps[upper]
+		if !ok {
+			continue
+		}
+		if upper == "IS" && i+1 < len(tokens) && strings.EqualFold(tokens[i+1].text, "NOT") {
+			continue
+		}
+		if ref, ok := columnRefBefore(tokens, i); ok {
+			ref.equality = equality
+			filters = append(filters, ref)
+		}
+		if ref, ok := columnRefAfter(tokens, i); ok {
+			ref.equality = equality
+			filters = append(filters, ref)
+		}
+	}
+	return filters
+}
+
+// tableAliases maps the lowercased aliases a query gives tables in its FROM
+// and JOIN clauses, like u in "FROM users AS u" or "JOIN users u", to the
+// table names, from the tokens of the query.
+func tableAliases(tokens []sqlToken) map[string]string {
+	aliases := map[string]string{}
+	inFrom := false
+	for i := 0; i < len(tokens); i++ {
+		upper := strings.ToUpper(tokens[i].text)
+		if !tokens[i].quoted {
+			switch {
+			case upper == "FROM" || upper == "JOIN":
+				inFrom = true
+				continue
+			case upper == "WHERE" || upper == "ON" || filterEnds[upper]:
+				inFrom = false
+				continue
+			}
+		}
+		if !inFrom || !tokens[i].name || (i > 0 && tokens[i-1].text == ".") {
+			continue
+		}
+		table, next := tokens[i].text, i+1
+		if next+1 < len(tokens) && tokens[next].text == "." && tokens[next+1].name {
+			table, next = tokens[next+1].text, next+2 // schema.table
+		}
+		if next < len(tokens) && strings.EqualFold(tokens[next].text, "AS") && !tokens[next].quoted {
+			next++
+		}
+		if next < len(tokens) && tokens[next].name {
+			aliases[strings.ToLower(tokens[next].text)] = table
+			next++
+		}
+		i = next - 1
+	}
+	return aliases
+}
+
+// columnRefBefore returns the column reference ending just before
+// tokens[i], e.g. "o"."price" or price.
+func columnRefBefore(tokens []sqlToken, i int) (filterColumn, bool) {
+	if i < 1 || !tokens[i-1].name {
+		return filterColumn{}, false
+	}
+	ref := filterColumn{column: tokens[i-1].text}
+	if i >= 3 && tokens[i-2].text == "." && tokens[i-3].name {
+		ref.qualifier = tokens[i-3].text
+	}
+	return ref, true
+}
+
+// columnRefAfter returns the column reference starting just after
+// tokens[i], unless it is a function call.
+func columnRefAfter(tokens []sqlToken, i int) (filterColumn, bool) {
+	if i+1 >= len(tokens) || !tokens[i+1].name {
+		return filterColumn{}, false
+	}
+	ref := filterColumn{column: tokens[i+1].text}
+	next := i + 2
+	if next+1 < len(tokens) && tokens[next].text == "." && tokens[next+1].name {
+		ref.qualifier, ref.column = ref.column, tokens[next+1].text
+		next += 2
+	}
+	if next < len(tokens) && tokens[next].text == "(" {
+		return filterColumn{}, false
+	}
+	return ref, true
+}
+
+// sqlToken is a token of a query as sqlTokens splits it.
+type sqlToken struct {
+	text   string // Unquoted for quoted names
+	name   bool   // An identifier, quoted or not, rather than a keyword, literal or operator
+	quoted bool   // A string literal or quoted name
+}
+
+// sqlKeywords are the keywords around filters that sqlTokens doesn't take
+// for names.
+var sqlKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"ON": true, "JOIN": true, "AS": true, "IS": true, "IN": true, "BETWEEN": true,
+	"LIKE": true, "GLOB": true, "NULL": true, "GROUP": true, "ORDER": true, "BY": true,
+	"LIMIT": true, "OFFSET": true, "HAVING": true, "WINDOW": true, "UNION": true,
+	"EXCEPT": true, "INTERSECT": true, "CASE": true, "WHEN": true, "THEN": true,
+	"ELSE": true, "END": true, "EXISTS": true, "TRUE": true, "FALSE": true,
+}
+
+// sqlTokens splits query into names, literals and operators, skipping
+// comments, for filterColumns.
+func sqlTokens(query string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		if next, ok := skipComment(query, i); ok {
+			i = next
+			continue
+		}
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '\'':
+			var text string
+			text, i = readQuoted(query, i, '\'')
+			tokens = append(tokens, sqlToken{text: text, quoted: true})
+		case c == '"' || c == '`':
+			var text string
+			text, i = readQuoted(query, i, c)
+			tokens = append(tokens, sqlToken{text: text, name: true, quoted: true})
+		case c == '[':
+			var text string
+			text, i = readQuoted(query, i, ']')
+			tokens = append(tokens, sqlToken{text: text, name: true, quoted: true})
+		case (c == ':' || c == '@' || c == '$') && i+1 < len(query) && isNameByte(query[i+1]):
+			// A named parameter, which is a value rather than a column.
+			start := i
+			for i++; i < len(query) && isNameByte(query[i]); i++ {
+			}
+			tokens = append(tokens, sqlToken{text: query[start:i]})
+		case isNameByte(c):
+			start := i
+			for i < len(query) && isNameByte(query[i]) {
+				i++
+			}
+			text := query[start:i]
+			isName := !sqlKeywords[strings.ToUpper(text)] && !(text[0] >= '0' && text[0] <= '9')
+			tokens = append(tokens, sqlToken{text: text, name: isName})
+		case strings.HasPrefix(query[i:], "<=") || strings.HasPrefix(query[i:], ">=") ||
+			strings.HasPrefix(query[i:], "==") || strings.HasPrefix(query[i:], "!=") ||
+			strings.HasPrefix(query[i:], "<>"):
+			tokens = append(tokens, sqlToken{text: query[i : i+2]})
+			i += 2
+		default:
+			tokens = append(tokens, sqlToken{text: query[i : i+1]})
+			i++
+		}
+	}
+	return tokens
+}
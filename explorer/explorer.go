@@ -0,0 +1,2987 @@
+// explorer.go
+
+// Package explorer implements a web UI and JSON API for browsing and querying
+// SQLite databases. Create an App with NewApp or NewAppWithDB and serve its
+// Handler, either on its own or mounted in another server.
+package explorer
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// staticMaxAge is how long browsers may cache files served from /static/.
+const staticMaxAge = 24 * time.Hour
+
+// Config holds the options used to construct an App. Only DBPath is required
+// by NewApp; NewAppWithDB needs none of them.
+type Config struct {
+	DBPath        string
+	Name          string   // Database name in the UI and /db/{name}/ routes, derived from DBPath if empty
+	Debug         bool     // Include internal error details in responses
+	TimeFormat    string   // Go time layout for date/time values, RFC 3339 if empty
+	TemplatesDir  string   // Directory of *.html templates overriding the embedded ones
+	Writable      bool     // Open the database read-write and enable imports
+	CacheSize     int      // Number of custom query results to cache, 0 disables
+	DSNParams     string   // Extra SQLite URI parameters, e.g. "immutable=1"
+	DeepPageMode  string   // How to serve pages past deepOffset, "warn" if empty
+	AttachPaths   []string // Extra database files to attach read-only
+	AttachNames   []string // Schema names for AttachPaths by position, derived from the file name if missing or empty
+	MaxRows       int      // Most rows a custom query or table export returns, 0 for no limit
+	AllowDownload bool     // Serve the database file at /api/download.db
+	MaxBodyBytes  int64    // Largest accepted request body, 0 for no limit
+
+	CORSOrigins     []string // Origins allowed to call the API, or "*" for any
+	CORSMaxAge      int      // Seconds browsers may cache preflight responses, 0 to omit
+	CORSCredentials bool     // Allow credentialed requests; incompatible with "*"
+
+	GeoLatCol string // Latitude column for ?_format=geojson, auto-detected if empty
+	GeoLngCol string // Longitude column for ?_format=geojson, auto-detected if empty
+
+	TailInterval time.Duration // How often /table/{name}/tail polls for new rows, 2s if zero
+	MetadataPath string        // JSON file of per-table settings such as default sorts
+
+	ShowShadowTables bool // List the internal tables backing FTS and R*Tree virtual tables
+
+	Admin      bool   // Serve the /api/admin/ endpoints
+	AdminToken string // Bearer token the admin endpoints require; must be set with Admin
+	AllowJSONP bool   // Accept ?_callback= on API GET requests
+
+	TrustedProxies []string // CIDR ranges or IPs of proxies whose X-Forwarded-For/X-Real-IP are believed
+
+	BlockedFunctions []string // SQL functions custom queries may not call, DefaultBlockedFunctions if nil
+
+	Description     string // Markdown shown above the table list on the index page
+	DescriptionFile string // File to read Description from instead
+
+	SlowQueryThreshold time.Duration // Queries taking longer are logged as warnings with their SQL and arguments, 0 disables
+	RedactQueryParams  bool          // Log query arguments as "?" instead of their values
+
+	NoCustomQuery  bool // Turn off arbitrary SQL: the /query and /api/query endpoints and their /db/{name}/ forms
+	MaxQueryLength int  // Longest custom query accepted, in characters, 0 for no limit
+
+	DBGlob string // Pattern of more database files to attach, kept up to date by WatchDBGlob; its first match is the main database if DBPath is empty
+
+	TenantHeader string // Request header whose value queries can use as :godatasette_tenant, empty to disable
+
+	StreamThreshold int // API responses up to this many bytes are buffered and sent with a Content-Length, 0 to stream all
+
+	WriteTimeout  time.Duration // Time each request has to write its response, 0 for no limit; needs the server to use ConnContext
+	ExportTimeout time.Duration // The same for exports and streams, like CSV responses and downloads
+
+	OTelEndpoint string // OTLP/HTTP collector to send request and query traces to, e.g. http://localhost:4318, empty to disable
+
+	BusyTimeout time.Duration // How long queries wait for another connection's lock before failing, 5s if zero; a _busy_timeout in DSNParams wins
+
+	Transformers map[string]RowTransformer // Transformers ?_transform= can select by name, DefaultTransformers if nil
+
+	DefaultRoute string // Where "/" redirects: "auto" for the only table of a single-table database, a path, or empty for the index
+
+	Driver string // SQLite driver: DriverMattn or DriverModernc; DefaultDriver if empty
+}
+
+// App holds application-wide dependencies, like the database connection.
+type App struct {
+	dbMu       sync.RWMutex           // Guards db and attached, which -watch-db and -db-glob may swap at runtime
+	reopenMu   sync.Mutex             // Serializes reopening db, see replaceDB
+	scopedMu   sync.Mutex             // Guards scoped
+	scoped     map[attachedDB]*sql.DB // Pools of single attached databases, see databaseConn
+	db         *sql.DB
+	templates  *template.Template
+	reports    map[string]bool // Names of the -templates-dir templates that aren't pages, for ?_template=
+	dbPath     string
+	dbName     string // Name of the database in /db/{name}/ routes
+	named      bool   // dbName was set by Config.Name rather than derived from dbPath
+	debug      bool
+	timeFormat string
+	writable   bool
+	driver     string // database/sql driver name, see Config.Driver
+	dsnParams  url.Values
+	cache      *queryCache // nil when caching is disabled
+	schema     schemaCache
+	relations  relationshipCache
+	queries    queryRegistry // Queries in progress, for /api/admin/queries
+
+	deepPageMode  string
+	attached      []attachedDB
+	maxRows       int
+	allowDownload bool
+	maxBodyBytes  int64
+	ownsDB        bool     // db was opened by NewApp and is closed by Close
+	tempFiles     []string // Decompressed copies of gzipped database files, removed by Close
+	compressed    bool     // The main database file is gzipped
+
+	corsOrigins     []string
+	corsMaxAge      int
+	corsCredentials bool
+
+	geoLatCol string
+	geoLngCol string
+
+	tailInterval time.Duration
+	metadata     Metadata
+
+	showShadowTables bool
+
+	admin      bool
+	adminToken string
+	allowJSONP bool
+
+	trustedProxies []*net.IPNet
+
+	blockedFunctions map[string]bool
+
+	description template.HTML
+
+	slowQueryThreshold time.Duration
+	redactQueryParams  bool
+
+	noCustomQuery  bool
+	maxQueryLength int
+
+	dbGlob    string
+	globFixed []attachedDB // The attachments from AttachPaths, which -db-glob rescans keep
+
+	tenantHeader string
+
+	streamThreshold int
+
+	writeTimeout  time.Duration
+	exportTimeout time.Duration
+
+	tracer *tracer // Nil unless tracing is on
+
+	transformers map[string]RowTransformer
+
+	defaultRoute string
+}
+
+// Table represents a single database table.
+type Table struct {
+	Name       string
+	Schema     string // "main", or the name of an attached database
+	Database   string // Name of the database the table belongs to, its label if it has one
+	Module     string // Virtual table module, e.g. "fts5" or "rtree"; empty for ordinary tables
+	RowCount   int64
+	ViewURL    string
+	APIDataURL string
+}
+
+// TableGroup is the tables of one database, as listed on the index page.
+type TableGroup struct {
+	Database string
+	Tables   []Table
+}
+
+// groupTables splits tables, as ordered by getTables, into runs belonging to
+// the same database.
+func groupTables(tables []Table) []TableGroup {
+	var groups []TableGroup
+	for _, t := range tables {
+		if n := len(groups); n > 0 && groups[n-1].Database == t.Database {
+			groups[n-1].Tables = append(groups[n-1].Tables, t)
+			continue
+		}
+		groups = append(groups, TableGroup{Database: t.Database, Tables: []Table{t}})
+	}
+	return groups
+}
+
+// Column describes a single column of a result set.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // Declared type, empty for expressions
+}
+
+// ColumnInfo describes a table column as reported by PRAGMA table_xinfo,
+// which unlike PRAGMA table_info includes generated and hidden columns.
+type ColumnInfo struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	NotNull   bool    `json:"notnull"`
+	Default   *string `json:"dflt_value"`          // Default value expression, nil if none
+	PK        int     `json:"pk"`                  // 1-based position within the primary key, 0 if not part of it
+	Hidden    int     `json:"hidden"`              // One of the column kinds below
+	Generated string  `json:"generated,omitempty"` // "virtual" or "stored" for generated columns
+}
+
+// Column kinds, the hidden field of PRAGMA table_xinfo.
+const (
+	columnOrdinary         = 0
+	columnHidden           = 1 // A hidden column of a virtual table, left out of SELECT *
+	columnGeneratedVirtual = 2 // GENERATED ALWAYS AS (...) VIRTUAL, computed when read
+	columnGeneratedStored  = 3 // GENERATED ALWAYS AS (...) STORED, computed when written
+)
+
+// isGenerated reports whether the column is computed from others, so it can
+// be read but not written.
+func (c ColumnInfo) isGenerated() bool {
+	return c.Hidden == columnGeneratedVirtual || c.Hidden == columnGeneratedStored
+}
+
+// PageData is the structure passed to HTML templates.
+type PageData struct {
+	DBName       string
+	Description  template.HTML // Rendered -description, shown on the index page
+	Tables       []Table
+	TableGroups  []TableGroup // Tables grouped by database, for the index page
+	Summary      *DBSummary   // Overview of the main database, for the index page
+	CurrentTable string
+	Columns      []Column
+	ColumnViews  []ColumnView // How to show each of Columns, set by renderTemplate on table pages
+	Rows         [][]interface{}
+	RowLinks     []string        // Detail page URL per row, nil when rows aren't linkable
+	RowStream    <-chan TableRow // Rows of the table view, read while rendering
+	RowsLinked   bool            // RowStream rows have detail page links
+	RowPK        string          // Primary key value shown on the row detail page
+	Search       string
+	Query        string
+	QueryParams  []QueryParam // Named parameters of Query, with their values
+	ParamsNeeded bool         // Query was held back until its parameters are filled in
+	Databases    []string     // Databases the query page can target
+	CurrentDB    string       // Database selected on the query page
+	Error        string
+	ErrorQuery   template.HTML // Failed query with the error location marked, if known
+	ErrorStatus  int           // HTTP status shown on the error page
+	Sample       bool          // Rows are a random sample rather than a page
+	Truncated    bool          // Query results were cut off at -max-rows
+	Page         string        // Name of the template being rendered, set by renderTemplate
+	Theme        string        // "light", "dark" or "system", set by renderTemplate
+	CustomQuery  bool          // The query page is enabled, set by renderTemplate
+	IndexURL     string        // Where the index is served, set by renderTemplate
+	CurrentPage  int
+	NextPage     int
+	PrevPage     int
+	HasNextPage  bool
+	TotalPages   int
+	FirstPage    int
+	LastPage     int
+	Pages        []PageLink // Numbered pager entries
+	PrevURL      string
+	NextURL      string
+	PageParams   url.Values // Query parameters other than page, kept by the jump-to-page form
+	Permalink    string     // URL pinning the rows shown by rowid, empty if they can't be pinned
+	Pinned       bool       // Rows are a permalinked rowid range rather than a page
+	HasRowid     bool       // The table has a rowid, so the view can show it
+	ShowRowid    bool       // The rowid is shown as the first column
+	Sort         string     // The ?_sort= order of the table view, if any
+
+	ColumnChoices []ColumnChoice // Every column of the table, for the column picker
+	API           *APIRequest    // JSON API request returning the same data, nil if there is none
+	Frozen        string         // Column the table view keeps in view when scrolling sideways, if any
+	Exports       []ExportLink   // Downloads of every row of the table view, empty if there are none
+
+	RowNumbers bool // Rows start with a "#" column numbering them from RowOffset+1
+	RowOffset  int  // Rows of the view before this page
+}
+
+const rowsPerPage = 50
+
+// themeCookie stores the user's color theme choice.
+const themeCookie = "theme"
+
+// timeLayouts are the layouts tried, in order, when parsing textual values of
+// date/time columns. They cover the formats SQLite's date functions produce
+// plus common ISO 8601 variants.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// templateFuncs are the helper functions available to all HTML templates.
+var templateFuncs = template.FuncMap{
+	"pathEscape":  url.PathEscape,
+	"highlight":   highlight,
+	"renderCell":  renderCell,
+	"formatBytes": formatBytes,
+	"add":         func(a, b int) int { return a + b },
+}
+
+const (
+	defaultValuesLimit = 100
+	maxValuesLimit     = 1000
+)
+
+// sourceTableRe matches simple single-table queries (no joins or subqueries
+// in the FROM clause) and captures the table name.
+var sourceTableRe = regexp.MustCompile(`(?is)^\s*SELECT\s.+?\sFROM\s+("(?:[^"]|"")+"|\[[^\]]+\]|` + "`[^`]+`" + `|[A-Za-z_]\w*)\s*(?:(?:AS\s+)?[A-Za-z_]\w*\s*)?(?:(?:WHERE|ORDER|GROUP|LIMIT)\s.*)?;?\s*$`)
+
+// NewApp creates an App for the database file at cfg.DBPath, opening it
+// read-only unless cfg.Writable is set, and attaching cfg.AttachPaths and the
+// files matching cfg.DBGlob. Gzip-compressed files are decompressed to temporary files first, and can
+// only be opened read-only.
+func NewApp(cfg Config) (*App, error) {
+	if cfg.DBPath == "" && cfg.DBGlob != "" {
+		matches, err := filepath.Glob(cfg.DBGlob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database glob %q: %w", cfg.DBGlob, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no database files match %q", cfg.DBGlob)
+		}
+		cfg.DBPath = matches[0]
+	}
+	dbPath := cfg.DBPath
+
+	// Check if the database file exists
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("database file not found at path: %s", dbPath)
+	}
+
+	// Connect to the SQLite database
+	driverName, err := validateDriver(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Driver = driverName
+	dsnParams, err := parseDSNParams(cfg.DSNParams)
+	if err != nil {
+		return nil, err
+	}
+	if err := setBusyTimeout(driverName, dsnParams, cfg.BusyTimeout); err != nil {
+		return nil, err
+	}
+	for _, path := range cfg.AttachPaths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, fmt.Errorf("database file not found at path: %s", path)
+		}
+	}
+	attached, err := parseAttachments(cfg.AttachPaths, cfg.AttachNames, databaseName(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	// Gzipped databases are decompressed to temporary files, removed by Close.
+	var tempFiles []string
+	removeTempFiles := func() {
+		for _, path := range tempFiles {
+			os.Remove(path)
+		}
+	}
+	openPath, temp, err := openablePath(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if temp {
+		tempFiles = append(tempFiles, openPath)
+		if cfg.Writable {
+			removeTempFiles()
+			return nil, fmt.Errorf("compressed database %s can only be opened read-only", dbPath)
+		}
+	}
+	for i, att := range attached {
+		path, temp, err := openablePath(att.Path)
+		if err != nil {
+			removeTempFiles()
+			return nil, err
+		}
+		if temp {
+			tempFiles = append(tempFiles, path)
+			attached[i].Path = path
+		}
+	}
+
+	fixed := attached
+	if cfg.DBGlob != "" {
+		if temp {
+			removeTempFiles()
+			return nil, fmt.Errorf("-db-glob can't be used with compressed database %s", dbPath)
+		}
+		attached, err = globAttachments(cfg.DBGlob, fixed, nil, dbPath, databaseName(cfg))
+		if err != nil {
+			removeTempFiles()
+			return nil, err
+		}
+	}
+
+	db, err := openDB(driverName, openPath, cfg.Writable, dsnParams, attached)
+	if err != nil {
+		removeTempFiles()
+		return nil, err
+	}
+
+	app, err := newApp(db, cfg)
+	if err != nil {
+		db.Close()
+		removeTempFiles()
+		return nil, err
+	}
+	app.dsnParams = dsnParams
+	app.attached = attached
+	app.dbGlob = cfg.DBGlob
+	app.globFixed = fixed
+	app.ownsDB = true
+	app.tempFiles = tempFiles
+	app.compressed = openPath != dbPath
+	return app, nil
+}
+
+// NewAppWithDB creates an App for an already open SQLite database, for
+// embedding the explorer in another program. The caller keeps ownership of
+// db: Close leaves it open. cfg.DSNParams, cfg.BusyTimeout, cfg.AttachPaths
+// and cfg.DBGlob are ignored since the connection already exists, and cfg.Writable only
+// enables the import API. cfg.DBPath is optional; without it the query cache is disabled,
+// as it relies on the file's modification time, and WatchDB does nothing. cfg.Driver is
+// only used when WatchDB reopens the file.
+func NewAppWithDB(db *sql.DB, cfg Config) (*App, error) {
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	var err error
+	if cfg.Driver, err = validateDriver(cfg.Driver); err != nil {
+		return nil, err
+	}
+	return newApp(db, cfg)
+}
+
+// newApp holds the setup shared by NewApp and NewAppWithDB.
+func newApp(db *sql.DB, cfg Config) (*App, error) {
+	deepPageMode := cfg.DeepPageMode
+	if deepPageMode == "" {
+		deepPageMode = deepPageWarn
+	}
+	if !validDeepPageMode(deepPageMode) {
+		return nil, fmt.Errorf("invalid deep page mode %q: must be warn, error or rowid", deepPageMode)
+	}
+
+	if err := validateCORS(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Admin && cfg.AdminToken == "" {
+		return nil, errNoAdminToken
+	}
+	trustedProxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	tailInterval := cfg.TailInterval
+	if tailInterval == 0 {
+		tailInterval = defaultTailInterval
+	}
+	if tailInterval < 0 {
+		return nil, fmt.Errorf("invalid tail interval %s: must be positive", tailInterval)
+	}
+	if cfg.SlowQueryThreshold < 0 {
+		return nil, fmt.Errorf("invalid slow query threshold %s: must not be negative", cfg.SlowQueryThreshold)
+	}
+
+	timeFormat := cfg.TimeFormat
+	if timeFormat == "" {
+		timeFormat = time.RFC3339
+	}
+
+	templates, reports, err := loadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := loadMetadata(cfg.MetadataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	description, err := loadDescription(cfg.Description, cfg.DescriptionFile)
+	if err != nil {
+		return nil, err
+	}
+
+	blockedFunctions := cfg.BlockedFunctions
+	if blockedFunctions == nil {
+		blockedFunctions = DefaultBlockedFunctions
+	}
+
+	if err := validateDefaultRoute(cfg.DefaultRoute); err != nil {
+		return nil, err
+	}
+
+	transformers := cfg.Transformers
+	if transformers == nil {
+		transformers = DefaultTransformers
+	}
+
+	var tracer *tracer
+	if cfg.OTelEndpoint != "" {
+		if tracer, err = newTracer(cfg.OTelEndpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	var cache *queryCache
+	if cfg.CacheSize > 0 && cfg.DBPath != "" {
+		cache = newQueryCache(cfg.CacheSize, cfg.DBPath)
+	}
+
+	app := &App{
+		db:         db,
+		templates:  templates,
+		reports:    reports,
+		dbPath:     cfg.DBPath,
+		dbName:     databaseName(cfg),
+		named:      cfg.Name != "",
+		debug:      cfg.Debug,
+		timeFormat: timeFormat,
+		writable:   cfg.Writable,
+		cache:      cache,
+
+		deepPageMode:  deepPageMode,
+		maxRows:       cfg.MaxRows,
+		allowDownload: cfg.AllowDownload,
+		maxBodyBytes:  cfg.MaxBodyBytes,
+
+		corsOrigins:     cfg.CORSOrigins,
+		corsMaxAge:      cfg.CORSMaxAge,
+		corsCredentials: cfg.CORSCredentials,
+
+		geoLatCol: cfg.GeoLatCol,
+		geoLngCol: cfg.GeoLngCol,
+
+		tailInterval: tailInterval,
+		metadata:     metadata,
+
+		showShadowTables: cfg.ShowShadowTables,
+
+		admin:      cfg.Admin,
+		adminToken: cfg.AdminToken,
+		allowJSONP: cfg.AllowJSONP,
+
+		trustedProxies: trustedProxies,
+
+		blockedFunctions: blockedFunctionSet(blockedFunctions),
+
+		description: description,
+
+		slowQueryThreshold: cfg.SlowQueryThreshold,
+		redactQueryParams:  cfg.RedactQueryParams,
+
+		noCustomQuery:  cfg.NoCustomQuery,
+		maxQueryLength: cfg.MaxQueryLength,
+
+		tenantHeader: http.CanonicalHeaderKey(cfg.TenantHeader),
+
+		streamThreshold: cfg.StreamThreshold,
+
+		writeTimeout:  cfg.WriteTimeout,
+		exportTimeout: cfg.ExportTimeout,
+
+		tracer: tracer,
+
+		transformers: transformers,
+
+		defaultRoute: cfg.DefaultRoute,
+
+		driver: cfg.Driver,
+	}
+	app.checkMetadata(context.Background())
+	return app, nil
+}
+
+// databaseName returns cfg.Name, or failing that the database file name
+// without its extension, or "main" for an unnamed database.
+func databaseName(cfg Config) string {
+	switch {
+	case cfg.Name != "":
+		return cfg.Name
+	case cfg.DBPath != "":
+		return baseName(cfg.DBPath)
+	default:
+		return "main"
+	}
+}
+
+// Handler returns an http.Handler serving the explorer's pages, API and
+// static files. Its links are absolute, so mount it at the root of a server
+// or host rather than under a path prefix.
+func (a *App) Handler() http.Handler {
+	// Subtree patterns ending in "/" must also be listed in subtreeRoots.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", a.handleIndex)
+	mux.HandleFunc(indexPath, a.handleIndex)
+	mux.HandleFunc("/table/", a.handleTable)
+	mux.HandleFunc("/theme", a.handleTheme)
+	mux.HandleFunc("/metrics", a.handleMetrics)
+	mux.Handle("/static/", staticHandler())
+
+	// API endpoints
+	mux.HandleFunc("/api/tables", a.handleAPITables)
+	mux.HandleFunc("/api/table/", a.handleAPITableData)
+	mux.HandleFunc("/api/download.db", a.handleAPIDownload)
+	mux.HandleFunc("/api/version", a.handleAPIVersion)
+	mux.HandleFunc("/api/relationships", a.handleAPIRelationships)
+	mux.HandleFunc("/api/summary", a.handleAPISummary)
+	if !a.noCustomQuery {
+		mux.HandleFunc("/query", a.handleQuery)
+		mux.HandleFunc("/db/", a.handleDB)
+		mux.HandleFunc("/api/query", a.handleAPIQuery)
+		mux.HandleFunc("/api/explain", a.handleAPIExplain)
+		mux.HandleFunc("/api/batch", a.handleAPIBatch)
+		mux.HandleFunc("/api/db/", a.handleAPIDB)
+	}
+	if a.admin {
+		mux.HandleFunc("/api/admin/integrity", a.requireAdmin(a.handleAPIAdminIntegrity))
+		mux.HandleFunc("/api/admin/queries", a.requireAdmin(a.handleAPIAdminQueries))
+		mux.HandleFunc("/api/admin/queries/", a.requireAdmin(a.handleAPIAdminQueries))
+	}
+
+	var handler http.Handler = a.bufferResponses(a.withAPIOptions(withoutTrailingSlash(mux)))
+	handler = a.withWriteDeadline(handler)
+	if a.maxBodyBytes > 0 {
+		handler = a.limitBodies(handler)
+	}
+	if len(a.corsOrigins) > 0 {
+		handler = a.withCORS(handler)
+	}
+	if a.tracer != nil {
+		handler = a.withTracing(handler)
+	}
+	return handler
+}
+
+// limitBodies caps the size of every request body at maxBodyBytes. Reading
+// past the cap fails, and handlers report that as 413 (see isBodyTooLarge).
+func (a *App) limitBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, a.maxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Close sends the traces still waiting, closes the database if NewApp opened
+// it, and removes the temporary copies of gzipped database files. Databases
+// passed to NewAppWithDB are left open.
+func (a *App) Close() error {
+	if a.tracer != nil {
+		a.tracer.stop()
+	}
+	if !a.ownsDB {
+		return nil
+	}
+	err := a.conn().Close()
+	for _, db := range a.takeScopedConns() {
+		db.Close()
+	}
+	for _, path := range a.tempFiles {
+		if rmErr := os.Remove(path); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
+
+// displayName returns the database name shown in page titles and headers:
+// its label if it was given one, or else its file name.
+func (a *App) displayName() string {
+	if a.dbPath != "" && !a.named {
+		return filepath.Base(a.dbPath)
+	}
+	return a.dbName
+}
+
+// sqliteURIParams are the SQLite URI parameters accepted by -dsn-params, in
+// addition to the driver's own "_"-prefixed options. "mode" is
+// deliberately excluded; it is controlled by -writable.
+var sqliteURIParams = map[string]bool{
+	"cache":     true,
+	"immutable": true,
+	"nolock":    true,
+	"psow":      true,
+	"vfs":       true,
+}
+
+// parseDSNParams validates extra connection URI parameters given as a query
+// string, e.g. "immutable=1&cache=shared".
+func parseDSNParams(raw string) (url.Values, error) {
+	params, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN parameters %q: %w", raw, err)
+	}
+	for key := range params {
+		switch {
+		case key == "mode":
+			return nil, fmt.Errorf("invalid DSN parameters: mode is set by -writable")
+		case !sqliteURIParams[key] && !strings.HasPrefix(key, "_"):
+			return nil, fmt.Errorf("invalid DSN parameters: unknown parameter %q", key)
+		}
+	}
+	return params, nil
+}
+
+// openDB opens and pings the SQLite database at dbPath with driverName,
+// read-only unless writable is set. params are appended to the connection
+// URI. Any attached databases are attached read-only to every connection.
+func openDB(driverName, dbPath string, writable bool, params url.Values, attached []attachedDB) (*sql.DB, error) {
+	dsn := driverDSN(driverName, dbPath, writable, params)
+	var db *sql.DB
+	if len(attached) > 0 {
+		d, err := lookupDriver(driverName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		db = sql.OpenDB(newAttachConnector(d, dsn, attached))
+	} else {
+		var err error
+		if db, err = sql.Open(driverName, dsn); err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+// loadTemplates parses the embedded HTML templates and then, if dir is set,
+// any *.html files in dir. A file on disk replaces the embedded template of the
+// same name, so only the templates being customized need to be provided. The
+// other files in dir are report templates, returned by name without the
+// extension.
+func loadTemplates(dir string) (*template.Template, map[string]bool, error) {
+	// Parse HTML templates from the embedded filesystem
+	templates, err := template.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	reports := map[string]bool{}
+	if dir == "" {
+		return templates, reports, nil
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, nil, fmt.Errorf("templates directory not found at path: %s", dir)
+	}
+	overrides, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list templates in %s: %w", dir, err)
+	}
+	if len(overrides) == 0 {
+		log.Printf("No *.html templates found in %s, using built-in templates", dir)
+		return templates, reports, nil
+	}
+	builtin := map[string]bool{}
+	for _, t := range templates.Templates() {
+		builtin[t.Name()] = true
+	}
+	if templates, err = templates.ParseFiles(overrides...); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse templates from %s: %w", dir, err)
+	}
+	for _, path := range overrides {
+		name := filepath.Base(path)
+		if builtin[name] {
+			log.Printf("Using template %s", path)
+			continue
+		}
+		reports[strings.TrimSuffix(name, ".html")] = true
+		log.Printf("Using report template %s", path)
+	}
+	return templates, reports, nil
+}
+
+// --- HTTP Handlers (HTML) ---
+
+// handleIndex displays the homepage with a list of tables.
+func (a *App) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed", nil)
+		return
+	}
+	if r.URL.Path != "/" && r.URL.Path != indexPath {
+		a.renderError(w, r, http.StatusNotFound, "Page not found", nil)
+		return
+	}
+	if r.URL.Path == "/" && r.URL.RawQuery == "" && a.defaultRoute != "" {
+		target, err := a.landingRedirect(r.Context())
+		if err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, "Failed to list tables", err)
+			return
+		}
+		if target != "" {
+			http.Redirect(w, r, target, http.StatusFound)
+			return
+		}
+	}
+
+	search := r.URL.Query().Get("search")
+	tables, _, err := a.getTables(r.Context(), search, 0, 0)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to list tables", err)
+		return
+	}
+	summary, err := a.dbSummary(r.Context(), tables)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to summarize database", err)
+		return
+	}
+
+	data := PageData{
+		DBName:      a.displayName(),
+		Description: a.description,
+		Tables:      tables,
+		TableGroups: groupTables(tables),
+		Summary:     summary,
+		Databases:   a.databaseNames(),
+		Search:      search,
+	}
+	a.renderTemplate(w, r, "index.html", data)
+}
+
+// handleTable displays data for a specific table with pagination.
+func (a *App) handleTable(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed", nil)
+		return
+	}
+	tableName, subpath, err := splitTableRoute(strings.TrimPrefix(r.URL.EscapedPath(), "/table/"))
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, "Invalid table path", nil)
+		return
+	}
+	if tableName == "" {
+		a.renderError(w, r, http.StatusBadRequest, "Table name not specified", nil)
+		return
+	}
+	exists, err := a.tableExists(r.Context(), tableName)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to look up table", err)
+		return
+	}
+	if !exists {
+		a.renderError(w, r, http.StatusNotFound, "Table not found", nil)
+		return
+	}
+	if pk := strings.TrimPrefix(subpath, "row/"); pk != subpath {
+		a.handleRow(w, r, tableName, pk)
+		return
+	}
+	if subpath == "random" {
+		a.handleRandom(w, r, tableName)
+		return
+	}
+	if subpath == "tail" {
+		a.handleTail(w, r, tableName)
+		return
+	}
+	if subpath != "" {
+		a.renderError(w, r, http.StatusNotFound, "Page not found", nil)
+		return
+	}
+
+	params := newQueryParams(r)
+	page := params.int("page", 1, 1, 0)
+	start, end, pinned := pinnedRange(params)
+	cols, err := a.viewColumns(w, r, tableName)
+	var unknownCols *unknownColumnsError
+	if errors.As(err, &unknownCols) {
+		params.fail("_cols", err.Error())
+	} else if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table schema", err)
+		return
+	}
+	rowid := params.oneOf("_rowid", "off", "on", "off") == "on"
+	rownum := params.oneOf("_rownum", "off", "on", "off") == "on"
+	hasRowid, err := a.hasRowid(r.Context(), tableName)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table schema", err)
+		return
+	}
+	sort, err := a.sortParam(r.Context(), params, tableName)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table schema", err)
+		return
+	}
+	if pinned && len(sort) > 0 {
+		params.fail("_sort", "_sort can't be combined with _start, which follows rowid order")
+	}
+	freeze, err := a.freezeParam(r.Context(), params, tableName)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table schema", err)
+		return
+	}
+	if err := params.err(); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	search := params.get("_search")
+	view := tableView{Search: search, Columns: cols, Rowid: rowid && hasRowid, Sort: sort, Freeze: freeze}
+	if pinned {
+		a.handlePinnedRows(w, r, tableName, start, end, view)
+		return
+	}
+	totalRows, err := a.countRows(r.Context(), tableName, search)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to count table rows", err)
+		return
+	}
+	totalPages := pageCount(totalRows)
+	if clamped := clampPage(page, totalPages); clamped != page {
+		// Send out-of-range pages to the nearest valid one rather than
+		// rendering an empty grid.
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(clamped))
+		http.Redirect(w, r, r.URL.EscapedPath()+"?"+query.Encode(), http.StatusFound)
+		return
+	}
+
+	dataQuery, args, err := a.tablePageQuery(r.Context(), tableName, page, view)
+	if errors.Is(err, errDeepPage) {
+		a.renderError(w, r, http.StatusBadRequest, "Pages this deep into the table are disabled on this server", nil)
+		return
+	}
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table data", err)
+		return
+	}
+	permalink, err := a.permalink(r.Context(), tableName, page, view)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table data", err)
+		return
+	}
+	// Rows are streamed into the template as they are read; done cancels
+	// the reader if rendering fails partway.
+	ctx, done := a.trackQuery(r, dataQuery)
+	defer done()
+	columns, stream, linked, err := a.streamTable(ctx, tableName, dataQuery, args...)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table data", err)
+		return
+	}
+
+	data := PageData{
+		DBName:       a.displayName(),
+		CurrentTable: tableName,
+		Columns:      columns,
+		RowStream:    stream,
+		RowsLinked:   linked,
+		Search:       search,
+		CurrentPage:  page,
+		NextPage:     page + 1,
+		PrevPage:     page - 1,
+		HasNextPage:  page < totalPages,
+		TotalPages:   totalPages,
+		FirstPage:    1,
+		LastPage:     totalPages,
+		Permalink:    permalink,
+		HasRowid:     hasRowid,
+		ShowRowid:    view.Rowid,
+		Sort:         sortString(sort),
+
+		ColumnChoices: a.columnChoices(r.Context(), tableName, cols),
+		API:           a.tableAPIRequest(r, tableName, page, view),
+		Frozen:        freeze,
+		Exports:       tableExportLinks(tableName, view),
+
+		RowNumbers: rownum,
+		RowOffset:  (page - 1) * rowsPerPage,
+	}
+	query := r.URL.Query()
+	data.Pages = pageLinks(query, page, totalPages)
+	data.PrevURL = pageURL(query, page-1)
+	data.NextURL = pageURL(query, page+1)
+	query.Del("page")
+	data.PageParams = query
+
+	a.renderTemplate(w, r, "table.html", data)
+}
+
+// handleRow displays a single row of a table, looked up by primary key.
+func (a *App) handleRow(w http.ResponseWriter, r *http.Request, tableName, pk string) {
+	pkColumn, err := a.primaryKey(r.Context(), tableName)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table schema", err)
+		return
+	}
+	if pkColumn == "" {
+		pkColumn = "rowid"
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = ?", quoteIdent(tableName), quoteIdent(pkColumn))
+	columns, rows, err := a.executeCustomQuery(r.Context(), query, pk)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch row", err)
+		return
+	}
+	if len(rows) == 0 {
+		a.renderError(w, r, http.StatusNotFound, "Row not found", nil)
+		return
+	}
+
+	data := PageData{
+		DBName:       a.displayName(),
+		CurrentTable: tableName,
+		Columns:      columns,
+		Rows:         rows[:1],
+		RowPK:        pk,
+	}
+	a.renderTemplate(w, r, "row.html", data)
+}
+
+// handleRandom displays a random sample of a table's rows.
+func (a *App) handleRandom(w http.ResponseWriter, r *http.Request, tableName string) {
+	n, method, err := randomParams(r)
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	params := newQueryParams(r)
+	freeze, err := a.freezeParam(r.Context(), params, tableName)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table schema", err)
+		return
+	}
+	if err := params.err(); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	columns, rows, _, err := a.randomRows(r.Context(), tableName, n, method)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to sample table", err)
+		return
+	}
+
+	data := PageData{
+		DBName:       a.displayName(),
+		CurrentTable: tableName,
+		Columns:      columns,
+		Sample:       true,
+		API:          a.randomAPIRequest(r, tableName, n, method),
+		Frozen:       freeze,
+	}
+	links := a.rowLinks(r.Context(), tableName, columns, rows)
+	data.RowStream = sliceRows(rows, links)
+	data.RowsLinked = links != nil
+	a.renderTemplate(w, r, "table.html", data)
+}
+
+// handleQuery displays a form for custom SQL and shows results.
+func (a *App) handleQuery(w http.ResponseWriter, r *http.Request) {
+	a.serveQuery(w, r, "")
+}
+
+// handleDB serves routes scoped to a named database, currently only
+// /db/{name}/query.
+func (a *App) handleDB(w http.ResponseWriter, r *http.Request) {
+	dbName, subpath, err := splitTableRoute(strings.TrimPrefix(r.URL.EscapedPath(), "/db/"))
+	if err != nil || subpath != "query" {
+		a.renderError(w, r, http.StatusNotFound, "Page not found", nil)
+		return
+	}
+	a.serveQuery(w, r, dbName)
+}
+
+// serveQuery renders the query page for the named database, running the
+// submitted query on POST. If dbName is empty the database is taken from the
+// "db" form field, falling back to the default one.
+func (a *App) serveQuery(w http.ResponseWriter, r *http.Request, dbName string) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		a.renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed", nil)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		if isBodyTooLarge(err) {
+			a.renderError(w, r, http.StatusRequestEntityTooLarge, "Request body too large", nil)
+			return
+		}
+		a.renderError(w, r, http.StatusBadRequest, "Invalid form data", nil)
+		return
+	}
+	if dbName == "" {
+		dbName = r.FormValue("db")
+	}
+	if dbName == "" {
+		dbName = a.dbName
+	}
+	if !a.hasDatabase(dbName) {
+		a.renderError(w, r, http.StatusNotFound, "Database not found", nil)
+		return
+	}
+
+	report := r.FormValue("_template")
+	if report != "" && !a.reports[report] {
+		a.renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Unknown report template %q", report), nil)
+		return
+	}
+
+	query := r.FormValue("sql")
+	params, complete := a.formQueryParams(r, query)
+	status := http.StatusOK
+	data := PageData{
+		DBName:      a.displayName(),
+		Query:       query,
+		QueryParams: params,
+		Databases:   a.databaseNames(),
+		CurrentDB:   dbName,
+	}
+
+	// Reports run their query on GET too, so they can be linked to.
+	if (r.Method == http.MethodPost || report != "") && query != "" {
+		// Refuse queries over -max-query-length before looking at them.
+		// Then, as basic security, only allow SELECT statements.
+		if msg := a.queryTooLong(query, "The query"); msg != "" {
+			data.Error = msg
+			status = http.StatusBadRequest
+		} else if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
+			data.Error = "Only SELECT queries are allowed."
+		} else if fn := a.blockedFunction(query); fn != "" {
+			data.Error = fmt.Sprintf("The function %s() is not allowed in queries.", fn)
+			status = http.StatusForbidden
+		} else if tenant, err := a.tenantArgs(r, query); err != nil {
+			data.Error = err.Error()
+			status = http.StatusBadRequest
+		} else if !complete {
+			// The query's parameters only got their inputs with this
+			// response, so let the user fill them in before running it.
+			data.ParamsNeeded = true
+		} else {
+			args := make([]interface{}, len(params))
+			for i, p := range params {
+				args[i] = sql.Named(p.Name, p.Value)
+			}
+			args = append(args, tenant...)
+			ctx, done := a.trackQuery(r, query)
+			columns, rows, truncated, err := a.runCustomQuery(ctx, dbName, a.maxRows, query, args...)
+			done()
+			if err != nil {
+				data.Error = err.Error()
+				data.ErrorQuery = markError(query, sqlErrorDetail(query, err))
+			} else {
+				data.Columns = columns
+				data.Rows = rows
+				data.Truncated = truncated
+				if table := sourceTable(query); table != "" && dbName == a.dbName {
+					if exists, _ := a.tableExists(r.Context(), table); exists {
+						data.RowLinks = a.rowLinks(r.Context(), table, columns, rows)
+					}
+				}
+			}
+		}
+	}
+
+	if data.Columns != nil {
+		data.API = a.queryAPIRequest(r, dbName, query, params)
+	}
+	if report != "" {
+		a.renderReport(w, r, report, status, data)
+		return
+	}
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	a.renderTemplate(w, r, "query.html", data)
+}
+
+// renderReport renders the results of the query page through the report
+// template name from -templates-dir. The template only gets the query, its
+// parameters and its results. Queries that failed or still need parameter
+// values get the error page instead.
+func (a *App) renderReport(w http.ResponseWriter, r *http.Request, name string, status int, data PageData) {
+	switch {
+	case data.Query == "":
+		a.renderError(w, r, http.StatusBadRequest, "Missing 'sql' parameter", nil)
+		return
+	case data.Error != "":
+		if status == http.StatusOK {
+			status = http.StatusBadRequest
+		}
+		a.renderError(w, r, status, data.Error, nil)
+		return
+	case data.ParamsNeeded:
+		a.renderError(w, r, http.StatusBadRequest, "Missing values for the query's parameters", nil)
+		return
+	}
+	a.renderTemplate(w, r, name+".html", PageData{
+		DBName:      data.DBName,
+		Query:       data.Query,
+		QueryParams: data.QueryParams,
+		CurrentDB:   data.CurrentDB,
+		Columns:     data.Columns,
+		Rows:        data.Rows,
+		Truncated:   data.Truncated,
+	})
+}
+
+// formQueryParams returns the named parameters of query with the values
+// submitted for them in the query form, as fields named ":name". complete
+// reports whether the form had a field for each of them, even an empty one.
+// The tenant parameter is left out, as it is bound from a request header.
+func (a *App) formQueryParams(r *http.Request, query string) (params []QueryParam, complete bool) {
+	complete = true
+	for _, name := range placeholderNames(query) {
+		if a.isTenantParam(name) {
+			continue
+		}
+		values, ok := r.Form[":"+name]
+		p := QueryParam{Name: name}
+		if ok {
+			p.Value = values[0]
+		}
+		complete = complete && ok
+		params = append(params, p)
+	}
+	return params, complete
+}
+
+// handleTheme stores the chosen color theme in a cookie and sends the user back
+// to the page they came from.
+func (a *App) handleTheme(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		a.renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed", nil)
+		return
+	}
+
+	theme := r.FormValue("theme")
+	switch theme {
+	case "light", "dark":
+		http.SetCookie(w, &http.Cookie{
+			Name:     themeCookie,
+			Value:    theme,
+			Path:     "/",
+			MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	case "system":
+		http.SetCookie(w, &http.Cookie{Name: themeCookie, Path: "/", MaxAge: -1})
+	default:
+		a.renderError(w, r, http.StatusBadRequest, "Unknown theme", nil)
+		return
+	}
+
+	// Only redirect to a local path so the Referer can't be used as an open redirect.
+	target := "/"
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && strings.HasPrefix(ref.Path, "/") {
+		target = ref.RequestURI()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// handleMetrics exposes internal counters in the Prometheus text format.
+func (a *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var hits, misses uint64
+	if a.cache != nil {
+		hits, misses = a.cache.stats()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintln(w, "# HELP godatasette_query_cache_hits_total Custom queries served from the query cache.")
+	fmt.Fprintln(w, "# TYPE godatasette_query_cache_hits_total counter")
+	fmt.Fprintf(w, "godatasette_query_cache_hits_total %d\n", hits)
+	fmt.Fprintln(w, "# HELP godatasette_query_cache_misses_total Custom queries not found in the query cache.")
+	fmt.Fprintln(w, "# TYPE godatasette_query_cache_misses_total counter")
+	fmt.Fprintf(w, "godatasette_query_cache_misses_total %d\n", misses)
+}
+
+// staticHandler serves the embedded CSS/JS assets under /static/ with cache
+// headers. Content types are derived from the file extensions.
+func staticHandler() http.Handler {
+	files := http.FileServer(http.FS(staticFS))
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(staticMaxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r) // No directory listings
+			return
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// --- HTTP Handlers (JSON API) ---
+
+func (a *App) handleAPITables(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	params := newQueryParams(r)
+	search := params.get("search")
+	limit := params.int("limit", 0, 1, 0)
+	offset := params.int("offset", 0, 0, 0)
+	if err := params.err(); err != nil {
+		a.respondWithParamErrors(w, err)
+		return
+	}
+
+	tables, total, err := a.getTables(r.Context(), search, limit, offset)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get tables", err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"search": search,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+		"tables": tables,
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+func (a *App) handleAPITableData(w http.ResponseWriter, r *http.Request) {
+	tableName, subpath, err := splitTableRoute(strings.TrimPrefix(r.URL.EscapedPath(), "/api/table/"))
+	if err != nil {
+		a.respondWithError(w, http.StatusBadRequest, "Invalid table path")
+		return
+	}
+	if subpath == "import" {
+		// Imports may create the table, so they do their own existence check.
+		a.handleAPIImport(w, r, tableName)
+		return
+	}
+	if !allowMethods(w, r, http.MethodGet) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	exists, err := a.tableExists(r.Context(), tableName)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to look up table", err)
+		return
+	}
+	if !exists {
+		a.respondWithError(w, http.StatusNotFound, "Table not found")
+		return
+	}
+
+	switch {
+	case subpath == "":
+		// Fall through to the paginated table data below.
+	case subpath == "count":
+		a.handleAPITableCount(w, r, tableName)
+		return
+	case subpath == "stats":
+		a.handleAPIColumnStats(w, r, tableName)
+		return
+	case subpath == "random":
+		a.handleAPIRandom(w, r, tableName)
+		return
+	case subpath == "infer":
+		a.handleAPIInferTypes(w, r, tableName)
+		return
+	case subpath == "template":
+		a.handleAPIRowTemplate(w, r, tableName)
+		return
+	case strings.HasPrefix(subpath, "column/") && strings.HasSuffix(subpath, "/values"):
+		column := strings.TrimSuffix(strings.TrimPrefix(subpath, "column/"), "/values")
+		a.handleAPIColumnValues(w, r, tableName, column)
+		return
+	default:
+		a.respondWithError(w, http.StatusNotFound, "Unknown table endpoint")
+		return
+	}
+
+	params := newQueryParams(r)
+	format := params.oneOf("_format", "json", "json", "geojson", "yaml", "csv")
+	page := params.int("page", 1, 1, 0)
+	after, hasAfter := params.int64("_after")
+	all := params.oneOf("_all", "off", "on", "off") == "on"
+	rownum := params.oneOf("_rownum", "off", "on", "off") == "on"
+	transforms := a.transformParam(params)
+	csv := csvParams(params)
+	view := tableView{
+		Search:  params.get("_search"),
+		Columns: splitColumnsParam(params.values["_cols"]),
+	}
+	err = a.checkColumns(r.Context(), tableName, view.Columns)
+	var unknownCols *unknownColumnsError
+	if errors.As(err, &unknownCols) {
+		params.fail("_cols", err.Error())
+	} else if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+	view.Rowid, err = a.rowidParam(r.Context(), params, tableName)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+	view.Sort, err = a.sortParam(r.Context(), params, tableName)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+	if hasAfter && len(view.Sort) > 0 {
+		params.fail("_sort", "_sort can't be combined with _after, which follows rowid order")
+	}
+	if hasAfter && all {
+		params.fail("_all", "_all can't be combined with _after")
+	}
+	if hasAfter && rownum {
+		params.fail("_rownum", "_rownum can't be combined with _after, which has no row positions")
+	}
+	jsonCols, err := a.jsonColumnsParam(r, params, tableName, format)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+	withSchema := params.oneOf("_schema", "off", "on", "off") == "on"
+	if err := params.err(); err != nil {
+		a.respondWithParamErrors(w, err)
+		return
+	}
+	if hasAfter {
+		a.handleAPITableDataAfter(w, r, tableName, after, format, csv, view, transforms, jsonCols)
+		return
+	}
+	if all {
+		a.handleAPITableDataAll(w, r, tableName, format, csv, view, transforms, rownum, jsonCols)
+		return
+	}
+	search := view.Search
+
+	totalRows, err := a.countRows(r.Context(), tableName, search)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to count table rows", err)
+		return
+	}
+	totalPages := pageCount(totalRows)
+	page = clampPage(page, totalPages)
+
+	columns, rows, err := a.getTableData(r.Context(), tableName, page, view)
+	if errors.Is(err, errDeepPage) {
+		a.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table data", err)
+		return
+	}
+	columns, rows = transformRows(transforms, columns, rows)
+	if rownum {
+		columns, rows = numberRows(columns, rows, (page-1)*rowsPerPage)
+	}
+	rows = parseJSONColumns(columns, rows, jsonCols)
+	if format == "geojson" {
+		a.respondWithGeoJSON(w, columns, rows)
+		return
+	}
+	if format == "yaml" {
+		a.respondWithYAML(w, columns, rows)
+		return
+	}
+	if format == "csv" {
+		a.respondWithCSV(w, columns, rows, csv)
+		return
+	}
+
+	types, err := a.columnTypes(r.Context(), tableName, columns)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"tableName":   tableName,
+		"page":        page,
+		"rowsPerPage": rowsPerPage,
+		"totalRows":   totalRows,
+		"totalPages":  totalPages,
+		"columns":     columnNames(columns),
+		"columnTypes": types,
+		"rows":        rows,
+	}
+	if search != "" {
+		response["search"] = search
+	}
+	if withSchema {
+		schema, err := a.tableInfo(r.Context(), tableName)
+		if err != nil {
+			a.respondWithInternalError(w, r, "Failed to get table schema", err)
+			return
+		}
+		response["schema"] = schema
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleAPITableCount returns the number of rows in a table, or of those
+// matching ?_search=, without fetching any of them.
+func (a *App) handleAPITableCount(w http.ResponseWriter, r *http.Request, tableName string) {
+	count, err := a.countRows(r.Context(), tableName, r.URL.Query().Get("_search"))
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to count table rows", err)
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, map[string]int64{"count": count})
+}
+
+// handleAPITableDataAll serves ?_all=on: every row of the table view rather
+// than a page, up to -max-rows, for exporting what the table page shows.
+func (a *App) handleAPITableDataAll(w http.ResponseWriter, r *http.Request, tableName, format string, csv csvOptions, view tableView, transforms []RowTransformer, rownum bool, jsonCols []string) {
+	query, args, err := a.tableViewQuery(r.Context(), tableName, view)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table data", err)
+		return
+	}
+	columns, rows, truncated, err := a.queryRows(r.Context(), a.maxRows, query, args...)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table data", err)
+		return
+	}
+	columns, rows = transformRows(transforms, columns, rows)
+	if rownum {
+		columns, rows = numberRows(columns, rows, 0)
+	}
+	rows = parseJSONColumns(columns, rows, jsonCols)
+	if truncated {
+		w.Header().Set("X-Truncated", "true")
+	}
+	if format == "geojson" {
+		a.respondWithGeoJSON(w, columns, rows)
+		return
+	}
+	if format == "yaml" {
+		a.respondWithYAML(w, columns, rows)
+		return
+	}
+	if format == "csv" {
+		a.respondWithCSV(w, columns, rows, csv)
+		return
+	}
+
+	types, err := a.columnTypes(r.Context(), tableName, columns)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+	if rows == nil {
+		rows = [][]interface{}{}
+	}
+	response := map[string]interface{}{
+		"tableName":   tableName,
+		"columns":     columnNames(columns),
+		"columnTypes": types,
+		"rows":        rows,
+		"truncated":   truncated,
+	}
+	if view.Search != "" {
+		response["search"] = view.Search
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleAPITableDataAfter serves ?_after=<rowid>, the keyset alternative to
+// ?page= for paging through large tables.
+func (a *App) handleAPITableDataAfter(w http.ResponseWriter, r *http.Request, tableName string, afterID int64, format string, csv csvOptions, view tableView, transforms []RowTransformer, jsonCols []string) {
+	columns, rows, next, err := a.getTableDataAfter(r.Context(), tableName, afterID, view)
+	if errors.Is(err, errNoRowid) {
+		a.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table data", err)
+		return
+	}
+	columns, rows = transformRows(transforms, columns, rows)
+	rows = parseJSONColumns(columns, rows, jsonCols)
+	if format == "geojson" {
+		a.respondWithGeoJSON(w, columns, rows)
+		return
+	}
+	if format == "yaml" {
+		a.respondWithYAML(w, columns, rows)
+		return
+	}
+	if format == "csv" {
+		a.respondWithCSV(w, columns, rows, csv)
+		return
+	}
+
+	types, err := a.columnTypes(r.Context(), tableName, columns)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"tableName":   tableName,
+		"after":       afterID,
+		"next":        next,
+		"rowsPerPage": rowsPerPage,
+		"columns":     columnNames(columns),
+		"columnTypes": types,
+		"rows":        rows,
+	}
+	if view.Search != "" {
+		response["search"] = view.Search
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleAPIColumnValues returns the distinct values of a column, optionally
+// filtered by a substring search, for building filter dropdowns.
+func (a *App) handleAPIColumnValues(w http.ResponseWriter, r *http.Request, tableName, column string) {
+	col, err := a.lookupColumn(r.Context(), tableName, column)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+	if col == nil {
+		a.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown column '%s'", column))
+		return
+	}
+
+	params := newQueryParams(r)
+	limit := params.int("limit", defaultValuesLimit, 1, maxValuesLimit)
+	if err := params.err(); err != nil {
+		a.respondWithParamErrors(w, err)
+		return
+	}
+
+	query := fmt.Sprintf("SELECT DISTINCT %s FROM %s", quoteIdent(column), quoteIdent(tableName))
+	var args []interface{}
+	search := params.get("search")
+	if search != "" {
+		query += fmt.Sprintf(" WHERE %s LIKE ? ESCAPE '\\'", quoteIdent(column))
+		args = append(args, "%"+escapeLike(search)+"%")
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT ?", quoteIdent(column))
+	args = append(args, limit)
+
+	_, rows, err := a.executeCustomQuery(r.Context(), query, args...)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get column values", err)
+		return
+	}
+
+	values := make([]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = row[0]
+	}
+
+	response := map[string]interface{}{
+		"tableName": tableName,
+		"column":    column,
+		"search":    search,
+		"limit":     limit,
+		"values":    values,
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleAPIColumnStats returns summary statistics for a single column. Numeric
+// columns get count/min/max/avg/sum, all others get count/distinct. Both
+// include the number of NULLs.
+func (a *App) handleAPIColumnStats(w http.ResponseWriter, r *http.Request, tableName string) {
+	column := r.URL.Query().Get("column")
+	if column == "" {
+		a.respondWithError(w, http.StatusBadRequest, "Missing 'column' query parameter")
+		return
+	}
+	col, err := a.lookupColumn(r.Context(), tableName, column)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+	if col == nil {
+		a.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown column '%s'", column))
+		return
+	}
+
+	var (
+		query  string
+		fields []string
+	)
+	if isNumericType(col.Type) {
+		query = fmt.Sprintf("SELECT COUNT(%[1]s), MIN(%[1]s), MAX(%[1]s), AVG(%[1]s), SUM(%[1]s), COUNT(*) - COUNT(%[1]s) FROM %[2]s", quoteIdent(column), quoteIdent(tableName))
+		fields = []string{"count", "min", "max", "avg", "sum", "nulls"}
+	} else {
+		query = fmt.Sprintf("SELECT COUNT(%[1]s), COUNT(DISTINCT %[1]s), COUNT(*) - COUNT(%[1]s) FROM %[2]s", quoteIdent(column), quoteIdent(tableName))
+		fields = []string{"count", "distinct", "nulls"}
+	}
+
+	values := make([]interface{}, len(fields))
+	valuePtrs := make([]interface{}, len(fields))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	// The aggregates read the whole table, so they are listed among the
+	// running queries where an admin can cancel them.
+	ctx, done := a.trackQuery(r, query)
+	ctx, sp := a.traceQuery(ctx, query)
+	start := time.Now()
+	err = a.conn().QueryRowContext(ctx, query).Scan(valuePtrs...)
+	a.logQuery(query, nil, time.Since(start))
+	sp.fail(err)
+	sp.finish()
+	done()
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to compute column stats", err)
+		return
+	}
+
+	stats := make(map[string]interface{}, len(fields))
+	for i, field := range fields {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		stats[field] = values[i]
+	}
+
+	response := map[string]interface{}{
+		"tableName": tableName,
+		"column":    column,
+		"type":      col.Type,
+		"stats":     stats,
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleAPIRandom returns a random sample of a table's rows.
+func (a *App) handleAPIRandom(w http.ResponseWriter, r *http.Request, tableName string) {
+	n, method, err := randomParams(r)
+	if err != nil {
+		a.respondWithParamErrors(w, err)
+		return
+	}
+
+	columns, rows, method, err := a.randomRows(r.Context(), tableName, n, method)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to sample table", err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"tableName": tableName,
+		"method":    method,
+		"columns":   columnNames(columns),
+		"rows":      rows,
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+func (a *App) handleAPIQuery(w http.ResponseWriter, r *http.Request) {
+	a.serveAPIQuery(w, r, r.URL.Query().Get("db"))
+}
+
+// handleAPIDB serves API routes scoped to a named database, currently only
+// /api/db/{name}/query.
+func (a *App) handleAPIDB(w http.ResponseWriter, r *http.Request) {
+	dbName, subpath, err := splitTableRoute(strings.TrimPrefix(r.URL.EscapedPath(), "/api/db/"))
+	if err != nil || subpath != "query" {
+		a.respondWithError(w, http.StatusNotFound, "Unknown database endpoint")
+		return
+	}
+	a.serveAPIQuery(w, r, dbName)
+}
+
+// serveAPIQuery runs the ?sql= query against the named database, or the
+// default one if dbName is empty.
+func (a *App) serveAPIQuery(w http.ResponseWriter, r *http.Request, dbName string) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if dbName == "" {
+		dbName = a.dbName
+	}
+	if !a.hasDatabase(dbName) {
+		a.respondWithError(w, http.StatusNotFound, "Database not found")
+		return
+	}
+
+	req, status, err := a.readAPIQuery(r)
+	var invalid paramErrors
+	if errors.As(err, &invalid) {
+		a.respondWithParamErrors(w, err)
+		return
+	}
+	if err != nil {
+		a.respondWithError(w, status, err.Error())
+		return
+	}
+	query := req.SQL
+
+	if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
+		a.respondWithError(w, http.StatusForbidden, "Only SELECT queries are allowed.")
+		return
+	}
+	if fn := a.blockedFunction(query); fn != "" {
+		a.respondWithError(w, http.StatusForbidden, fmt.Sprintf("The function %s() is not allowed in queries.", fn))
+		return
+	}
+
+	maxRows := a.maxRows
+	if req.Size != 0 {
+		maxRows = req.Size
+	}
+
+	tenant, err := a.tenantArgs(r, query)
+	if err != nil {
+		a.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	run, args := query, append(req.args(), tenant...)
+	if req.Offset > 0 {
+		run, args = offsetQuery(query, args, req.Offset)
+	}
+	ctx, done := a.trackQuery(r, run)
+	columns, rows, truncated, err := a.runCustomQuery(ctx, dbName, maxRows, run, args...)
+	done()
+	if err != nil {
+		response := map[string]interface{}{"error": fmt.Sprintf("Query execution failed: %v", err)}
+		if detail := sqlErrorDetail(query, err); detail != nil {
+			response["error_detail"] = detail
+		}
+		a.respondWithJSON(w, http.StatusInternalServerError, response)
+		return
+	}
+	transforms, _ := a.lookupTransformers(req.Transform) // Checked by readAPIQuery
+	columns, rows = transformRows(transforms, columns, rows)
+
+	response := map[string]interface{}{
+		"database":   dbName,
+		"query":      query,
+		"columns":    columnNames(columns),
+		"rows":       rows,
+		"truncated":  truncated,
+		"offset":     req.Offset,
+		"nextOffset": nil,
+	}
+	if truncated {
+		response["nextOffset"] = req.Offset + len(rows)
+	}
+	if req.Checksum {
+		checksum := resultChecksum(columns, rows)
+		if checkResultETag(w, r, checksum) {
+			return
+		}
+		response["checksum"] = checksum
+	}
+	switch req.Format {
+	case "yaml":
+		a.respondWithYAML(w, columns, rows)
+		return
+	case "csv":
+		a.respondWithCSV(w, columns, rows, req.csvOptions())
+		return
+	}
+	if req.Shape == "objects" {
+		response["columns"] = uniqueColumnNames(columns)
+		objects := rowObjects(columns, rows)
+		if req.Sparse {
+			dropNulls(objects)
+		}
+		response["rows"] = objects
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+// apiQuery is a custom query submitted to /api/query, either as GET
+// parameters (sql, _size, _offset, _shape, _sparse, _format, _null, _checksum,
+// _transform) or as a
+// JSON POST body.
+type apiQuery struct {
+	SQL       string                 `json:"sql"`
+	Params    map[string]interface{} `json:"params"`    // Bound to :name, @name or $name
+	Size      int                    `json:"size"`      // Row cap, 0 for the server's -max-rows
+	Offset    int                    `json:"offset"`    // Result rows to skip before the first one returned
+	Shape     string                 `json:"shape"`     // "arrays" (default) or "objects"
+	Format    string                 `json:"format"`    // "json" (default), "yaml", which always uses the objects shape, or "csv"
+	Null      string                 `json:"null"`      // How CSV writes NULL, empty by default
+	Delim     string                 `json:"delimiter"` // CSV field separator: ",", ";", "tab" or "|"
+	CRLF      bool                   `json:"crlf"`      // End CSV lines with \r\n
+	BOM       bool                   `json:"bom"`       // Start CSV with a UTF-8 byte order mark
+	Checksum  bool                   `json:"checksum"`  // Add a checksum of the result, which also serves as its ETag
+	Transform string                 `json:"transform"` // Comma-separated RowTransformer names, see Config.Transformers
+	Sparse    bool                   `json:"sparse"`    // Leave NULL values out of objects; needs the objects shape
+}
+
+// readAPIQuery reads and validates the query from a GET or POST /api/query
+// request. Invalid parameters or body fields are reported together as
+// paramErrors; on other failures it also returns the HTTP status to respond
+// with.
+func (a *App) readAPIQuery(r *http.Request) (apiQuery, int, error) {
+	if r.Method != http.MethodPost {
+		params := newQueryParams(r)
+		req := apiQuery{
+			SQL:    params.get("sql"),
+			Size:   params.int("_size", 0, 1, a.maxRows),
+			Offset: params.int("_offset", 0, 0, 0),
+			Shape:  params.oneOf("_shape", "arrays", "arrays", "objects"),
+			Format: params.oneOf("_format", "json", "json", "yaml", "csv"),
+			Null:   params.get("_null"),
+		}
+		req.Checksum = params.oneOf("_checksum", "off", "on", "off") == "on"
+		req.Transform = params.get("_transform")
+		req.Sparse = params.oneOf("_sparse", "off", "on", "off") == "on"
+		if req.Sparse && req.Shape != "objects" {
+			params.fail("_sparse", "_sparse needs _shape=objects")
+		}
+		if _, err := a.lookupTransformers(req.Transform); err != nil {
+			params.fail("_transform", err.Error())
+		}
+		csv := csvParams(params)
+		req.Delim, req.CRLF, req.BOM = params.get("_delimiter"), csv.CRLF, csv.BOM
+		if req.SQL == "" {
+			params.fail("sql", "Missing 'sql' query parameter")
+		} else if msg := a.queryTooLong(req.SQL, "sql"); msg != "" {
+			params.fail("sql", msg)
+		}
+		return req, http.StatusBadRequest, params.err()
+	}
+
+	var req apiQuery
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return req, http.StatusUnsupportedMediaType, fmt.Errorf("Content-Type must be application/json")
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		if isBodyTooLarge(err) {
+			return req, http.StatusRequestEntityTooLarge, fmt.Errorf("Request body too large")
+		}
+		return req, http.StatusBadRequest, fmt.Errorf("Failed to parse request body: %v", err)
+	}
+
+	var invalid paramErrors
+	if req.SQL == "" {
+		invalid = append(invalid, ParamError{"sql", "Missing 'sql' field"})
+	} else if msg := a.queryTooLong(req.SQL, "sql"); msg != "" {
+		invalid = append(invalid, ParamError{"sql", msg})
+	}
+	if req.Size != 0 && (req.Size < 1 || (a.maxRows > 0 && req.Size > a.maxRows)) {
+		invalid = append(invalid, ParamError{"size", rangeMessage("size", 1, a.maxRows)})
+	}
+	if req.Offset < 0 {
+		invalid = append(invalid, ParamError{"offset", rangeMessage("offset", 0, 0)})
+	}
+	if _, ok := req.Params[tenantParam]; ok && a.tenantHeader != "" {
+		invalid = append(invalid, ParamError{"params", fmt.Sprintf("params can't set %s, which comes from the %s header", tenantParam, a.tenantHeader)})
+	}
+	if req.Shape != "" && req.Shape != "arrays" && req.Shape != "objects" {
+		invalid = append(invalid, ParamError{"shape", "shape must be 'arrays' or 'objects'"})
+	} else if req.Sparse && req.Shape != "objects" {
+		invalid = append(invalid, ParamError{"sparse", "sparse needs \"shape\": \"objects\""})
+	}
+	if req.Format != "" && req.Format != "json" && req.Format != "yaml" && req.Format != "csv" {
+		invalid = append(invalid, ParamError{"format", "format must be 'json', 'yaml' or 'csv'"})
+	}
+	if _, ok := csvDelimiters[req.Delim]; req.Delim != "" && !ok {
+		invalid = append(invalid, ParamError{"delimiter", fmt.Sprintf("delimiter must be '%s'", strings.Join(csvDelimiterNames, "' or '"))})
+	}
+	if _, err := a.lookupTransformers(req.Transform); err != nil {
+		invalid = append(invalid, ParamError{"transform", err.Error()})
+	}
+	if len(invalid) > 0 {
+		return req, http.StatusBadRequest, invalid
+	}
+	return req, 0, nil
+}
+
+// csvOptions returns the CSV dialect the query asks for.
+func (q apiQuery) csvOptions() csvOptions {
+	return csvOptions{Null: q.Null, Delimiter: csvDelimiters[q.Delim], CRLF: q.CRLF, BOM: q.BOM}
+}
+
+// queryTooLong returns an error message naming the query as name if it is
+// longer than -max-query-length characters, or "" if it isn't.
+func (a *App) queryTooLong(query, name string) string {
+	if a.maxQueryLength <= 0 || utf8.RuneCountInString(query) <= a.maxQueryLength {
+		return ""
+	}
+	return fmt.Sprintf("%s must be at most %d characters long", name, a.maxQueryLength)
+}
+
+// offsetParam is the name offsetQuery binds the offset to, unlikely to
+// clash with the query's own parameters.
+const offsetParam = "godatasette_offset"
+
+// offsetQuery wraps query as a subquery that skips its first offset rows, for
+// paging through the results of /api/query. A LIMIT in query applies first,
+// and the rows keep query's order. A line break ends any trailing comment.
+func offsetQuery(query string, args []interface{}, offset int) (string, []interface{}) {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	wrapped := "SELECT * FROM (" + query + "\n) LIMIT -1 OFFSET :" + offsetParam
+	return wrapped, append(args, sql.Named(offsetParam, offset))
+}
+
+// args returns the query's parameters as named arguments, sorted by name so
+// equal requests share a query cache entry.
+func (q apiQuery) args() []interface{} {
+	names := make([]string, 0, len(q.Params))
+	for name := range q.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = sql.Named(name, jsonImportValue(q.Params[name]))
+	}
+	return args
+}
+
+// rowObjects converts rows to objects keyed by column name, made unique by
+// uniqueColumnNames so that no column is lost.
+func rowObjects(columns []Column, rows [][]interface{}) []map[string]interface{} {
+	names := uniqueColumnNames(columns)
+	objects := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		obj := make(map[string]interface{}, len(columns))
+		for j, name := range names {
+			obj[name] = row[j]
+		}
+		objects[i] = obj
+	}
+	return objects
+}
+
+// dropNulls removes the NULL values from objects made by rowObjects, for
+// sparse results where a missing key means NULL.
+func dropNulls(objects []map[string]interface{}) {
+	for _, obj := range objects {
+		for name, value := range obj {
+			if IsNull(value) {
+				delete(obj, name)
+			}
+		}
+	}
+}
+
+// uniqueColumnNames returns the column names to key objects by. A join like
+// SELECT * FROM a JOIN b can return several columns of the same name; the
+// first keeps it and the later ones get ":1", ":2" and so on appended, e.g.
+// id, id:1, skipping any suffixed name that is itself a column name.
+func uniqueColumnNames(columns []Column) []string {
+	taken := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		taken[col.Name] = true
+	}
+	seen := make(map[string]bool, len(columns))
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		name := col.Name
+		if seen[name] {
+			for n := 1; ; n++ {
+				candidate := name + ":" + strconv.Itoa(n)
+				if !taken[candidate] {
+					name = candidate
+					taken[name] = true
+					break
+				}
+			}
+		}
+		seen[col.Name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// --- Database Logic ---
+
+// databaseNames returns the names of the databases that queries can target:
+// the first -db file followed by any attached ones. Queries on the first one
+// can reach all of them, while those on an attached database only see its
+// own tables, see databaseConn.
+func (a *App) databaseNames() []string {
+	names := []string{a.dbName}
+	for _, db := range a.attachments() {
+		names = append(names, db.Name)
+	}
+	return names
+}
+
+// hasDatabase reports whether name is one of databaseNames.
+func (a *App) hasDatabase(name string) bool {
+	for _, n := range a.databaseNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getTables retrieves user-defined tables from the main and any attached
+// databases whose names contain search (case-insensitively), along with the
+// total number of matches. A limit of 0 returns all matching tables. Tables
+// are grouped by database, the main one first, and sorted by name within it.
+func (a *App) getTables(ctx context.Context, search string, limit, offset int) ([]Table, int, error) {
+	schemas, err := a.schemaNames(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	where := "type='table' AND name NOT LIKE 'sqlite_%' AND name LIKE ? ESCAPE '\\'"
+	pattern := "%" + escapeLike(search) + "%"
+
+	selects := make([]string, len(schemas))
+	var args []interface{}
+	for i, schema := range schemas {
+		selects[i] = fmt.Sprintf("SELECT ? AS schema_name, name, COALESCE(sql, '') AS sql FROM %s.sqlite_master WHERE %s", quoteIdent(schema), where)
+		args = append(args, schema, pattern)
+		if a.showShadowTables {
+			continue
+		}
+		shadows, err := a.shadowTables(ctx, schema)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(shadows) > 0 {
+			selects[i] += " AND name NOT IN (?" + strings.Repeat(", ?", len(shadows)-1) + ")"
+			for _, name := range shadows {
+				args = append(args, name)
+			}
+		}
+	}
+	union := strings.Join(selects, " UNION ALL ")
+
+	var total int
+	err = a.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+union+")", args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if limit <= 0 {
+		limit = -1 // SQLite treats a negative LIMIT as unbounded
+	}
+	query := "SELECT schema_name, name, sql FROM (" + union + ") ORDER BY schema_name <> 'main', schema_name, name LIMIT ? OFFSET ?;"
+	rows, err := a.conn().QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	type tableRef struct{ schema, name, ddl string }
+	var refs []tableRef
+	for rows.Next() {
+		var ref tableRef
+		if err := rows.Scan(&ref.schema, &ref.name, &ref.ddl); err != nil {
+			return nil, 0, err
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	tables := make([]Table, 0, len(refs))
+	for _, ref := range refs {
+		if ref.schema != "main" {
+			table := a.attachedTable(ctx, ref.schema, ref.name)
+			table.Module = virtualTableModule(ref.ddl)
+			tables = append(tables, table)
+			continue
+		}
+
+		// Get row count for each table
+		count, err := a.countRows(ctx, ref.name, "")
+		if err != nil {
+			log.Printf("Could not count rows for table %s: %v", ref.name, err)
+			count = -1 // Indicate an error
+		}
+
+		tables = append(tables, Table{
+			Name:       ref.name,
+			Schema:     ref.schema,
+			Database:   a.dbName,
+			Module:     virtualTableModule(ref.ddl),
+			RowCount:   count,
+			ViewURL:    fmt.Sprintf("/table/%s", url.PathEscape(ref.name)),
+			APIDataURL: fmt.Sprintf("/api/table/%s", url.PathEscape(ref.name)),
+		})
+	}
+	return tables, total, nil
+}
+
+// attachedTable describes a table of an attached database. The table pages
+// only serve the main database, so its URLs point at the query page and API
+// with a query selecting its first rows instead, or are empty when custom
+// queries are turned off.
+func (a *App) attachedTable(ctx context.Context, schema, name string) Table {
+	qualified := quoteIdent(schema) + "." + quoteIdent(name)
+	var count int64
+	if err := a.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM "+qualified).Scan(&count); err != nil {
+		log.Printf("Could not count rows for table %s.%s: %v", schema, name, err)
+		count = -1 // Indicate an error
+	}
+	params := url.Values{
+		"db":  {schema},
+		"sql": {fmt.Sprintf("SELECT * FROM %s LIMIT %d", qualified, rowsPerPage)},
+	}
+	table := Table{
+		Name:     name,
+		Schema:   schema,
+		Database: schema,
+		RowCount: count,
+	}
+	if !a.noCustomQuery {
+		table.ViewURL = "/query?" + params.Encode()
+		table.APIDataURL = "/api/query?" + params.Encode()
+	}
+	return table
+}
+
+// schemaNames returns the schema names of the main and attached databases,
+// as listed by PRAGMA database_list, skipping the temp schema.
+func (a *App) schemaNames(ctx context.Context) ([]string, error) {
+	rows, err := a.conn().QueryContext(ctx, "PRAGMA database_list")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var (
+			seq        int
+			name, file string
+		)
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return nil, err
+		}
+		if name != "temp" {
+			names = append(names, name)
+		}
+	}
+	return names, rows.Err()
+}
+
+// countRows returns the number of rows in a table that match search (see
+// searchFilter), or all rows if search is empty.
+func (a *App) countRows(ctx context.Context, tableName, search string) (int64, error) {
+	where, args, err := a.searchFilter(ctx, tableName, search)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", quoteIdent(tableName), where)
+	err = a.conn().QueryRowContext(ctx, countQuery, args...).Scan(&count)
+	return count, err
+}
+
+// tableView holds the request options that shape a page of table data.
+type tableView struct {
+	Search  string    // Only rows where a text column contains this, if set
+	Columns []string  // Columns to select, all if empty
+	Rowid   bool      // Select the rowid first, as rowidColumn
+	Sort    []SortKey // Sort order, the metadata's default sort if empty
+	Freeze  string    // Column the HTML view keeps in view when scrolling sideways, if shown
+}
+
+// getTableData retrieves one page of data for a given table, shaped by view.
+func (a *App) getTableData(ctx context.Context, tableName string, page int, view tableView) (columns []Column, rows [][]interface{}, err error) {
+	query, args, err := a.tablePageQuery(ctx, tableName, page, view)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.executeCustomQuery(ctx, query, args...)
+}
+
+// tablePageQuery builds the query for one page of a table, shaped by view.
+// The page size and offset are bound as arguments, so the SQL is the same for
+// every page of a given view.
+func (a *App) tablePageQuery(ctx context.Context, tableName string, page int, view tableView) (string, []interface{}, error) {
+	offset := (page - 1) * rowsPerPage
+	if offset >= deepOffset {
+		query, args, ok, err := a.deepPage(ctx, tableName, offset, view)
+		if ok || err != nil {
+			return query, args, err
+		}
+	}
+	query, args, err := a.tableViewQuery(ctx, tableName, view)
+	if err != nil {
+		return "", nil, err
+	}
+	return query + " LIMIT ? OFFSET ?", append(args, rowsPerPage, offset), nil
+}
+
+// tableViewQuery builds the query for every row of a table view, in its
+// order.
+func (a *App) tableViewQuery(ctx context.Context, tableName string, view tableView) (string, []interface{}, error) {
+	where, args, err := a.searchFilter(ctx, tableName, view.Search)
+	if err != nil {
+		return "", nil, err
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s%s", view.selectList(), quoteIdent(tableName), where, a.orderBy(tableName, view))
+	return query, args, nil
+}
+
+// searchFilter builds a WHERE clause matching rows where any text column
+// contains search (case-insensitively for ASCII, as with LIKE). It returns an
+// empty clause for an empty search, and one matching nothing if the table has
+// no text columns.
+func (a *App) searchFilter(ctx context.Context, tableName, search string) (string, []interface{}, error) {
+	if search == "" {
+		return "", nil, nil
+	}
+	columns, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return "", nil, err
+	}
+	pattern := "%" + escapeLike(search) + "%"
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, col := range columns {
+		if col.Hidden != columnHidden && isTextType(col.Type) {
+			conds = append(conds, quoteIdent(col.Name)+" LIKE ? ESCAPE '\\'")
+			args = append(args, pattern)
+		}
+	}
+	if len(conds) == 0 {
+		return " WHERE 0", nil, nil
+	}
+	return " WHERE (" + strings.Join(conds, " OR ") + ")", args, nil
+}
+
+// tableExists reports whether tableName is a user table listed in sqlite_master.
+func (a *App) tableExists(ctx context.Context, tableName string) (bool, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name = ?"
+	if err := a.conn().QueryRowContext(ctx, query, tableName).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// tableInfo returns the columns of a table as reported by PRAGMA table_xinfo.
+// Results are cached per table since the schema rarely changes.
+func (a *App) tableInfo(ctx context.Context, tableName string) ([]ColumnInfo, error) {
+	if columns, ok := a.schema.get(tableName); ok {
+		return columns, nil
+	}
+
+	rows, err := a.conn().QueryContext(ctx, fmt.Sprintf("PRAGMA table_xinfo(%s)", quoteIdent(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []ColumnInfo
+	for rows.Next() {
+		var (
+			cid int
+			col ColumnInfo
+		)
+		if err := rows.Scan(&cid, &col.Name, &col.Type, &col.NotNull, &col.Default, &col.PK, &col.Hidden); err != nil {
+			return nil, err
+		}
+		switch col.Hidden {
+		case columnGeneratedVirtual:
+			col.Generated = "virtual"
+		case columnGeneratedStored:
+			col.Generated = "stored"
+		}
+		columns = append(columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) > 0 { // Don't remember tables that don't exist (yet)
+		a.schema.put(tableName, columns)
+	}
+	return columns, nil
+}
+
+// lookupColumn returns the schema of the named column in tableName, or nil if
+// the table has no such column.
+func (a *App) lookupColumn(ctx context.Context, tableName, column string) (*ColumnInfo, error) {
+	columns, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range columns {
+		if columns[i].Name == column {
+			return &columns[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// primaryKey returns the name of a table's single-column primary key, or an
+// empty string if the table has no primary key or a composite one.
+func (a *App) primaryKey(ctx context.Context, tableName string) (string, error) {
+	columns, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return "", err
+	}
+
+	var pkColumn string
+	var pkCount int
+	for _, col := range columns {
+		if col.PK > 0 {
+			pkColumn = col.Name
+			pkCount++
+		}
+	}
+	if pkCount != 1 {
+		return "", nil
+	}
+	return pkColumn, nil
+}
+
+// rowLinks builds a detail page URL for each row when the result set contains
+// the primary key of tableName exactly once. It returns nil otherwise.
+func (a *App) rowLinks(ctx context.Context, tableName string, columns []Column, rows [][]interface{}) []string {
+	link := a.rowLinker(ctx, tableName, columns)
+	if link == nil {
+		return nil
+	}
+	links := make([]string, len(rows))
+	for i, row := range rows {
+		links[i] = link(row)
+	}
+	return links
+}
+
+// rowLinker returns a function building the detail page URL of a row when the
+// result set contains the primary key of tableName exactly once, or for tables
+// without a single-column primary key, the rowid exposed as rowidColumn. It
+// returns nil otherwise.
+func (a *App) rowLinker(ctx context.Context, tableName string, columns []Column) func(row []interface{}) string {
+	pkColumn, err := a.primaryKey(ctx, tableName)
+	if err != nil {
+		return nil
+	}
+	if pkColumn == "" {
+		// The detail page looks such rows up by rowid.
+		pkColumn = rowidColumn
+	}
+
+	pkIndex := -1
+	for i, col := range columns {
+		if strings.EqualFold(col.Name, pkColumn) {
+			if pkIndex != -1 {
+				return nil // Ambiguous, e.g. "SELECT id, id FROM t"
+			}
+			pkIndex = i
+		}
+	}
+	if pkIndex == -1 {
+		return nil
+	}
+
+	return func(row []interface{}) string {
+		return fmt.Sprintf("/table/%s/row/%s", url.PathEscape(tableName), url.PathEscape(fmt.Sprint(row[pkIndex])))
+	}
+}
+
+// runCustomQuery runs a user-submitted query on the database dbName (see
+// databaseConn), returning at most maxRows rows (all rows if maxRows is 0)
+// and whether more were available. Results are served from the query cache
+// when caching is enabled, for the main database only, as the cache only
+// watches its file for changes.
+func (a *App) runCustomQuery(ctx context.Context, dbName string, maxRows int, query string, args ...interface{}) ([]Column, [][]interface{}, bool, error) {
+	if dbName != a.dbName {
+		db, err := a.databaseConn(dbName)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return a.queryRowsOn(ctx, db, maxRows, query, args...)
+	}
+	if a.cache == nil {
+		return a.queryRows(ctx, maxRows, query, args...)
+	}
+	key := queryCacheKey(query, maxRows, args...)
+	if columns, rows, truncated, ok := a.cache.get(key); ok {
+		return columns, rows, truncated, nil
+	}
+	columns, rows, truncated, err := a.queryRows(ctx, maxRows, query, args...)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	a.cache.put(key, columns, rows, truncated)
+	return columns, rows, truncated, nil
+}
+
+// executeCustomQuery runs a given SQL query and returns all resulting rows.
+// The query is interrupted as soon as ctx is done, e.g. when the client
+// disconnects.
+func (a *App) executeCustomQuery(ctx context.Context, query string, args ...interface{}) ([]Column, [][]interface{}, error) {
+	columns, rows, _, err := a.queryRows(ctx, 0, query, args...)
+	return columns, rows, err
+}
+
+// queryRows runs a query like executeCustomQuery, but stops reading after
+// maxRows rows (unless maxRows is 0) so huge results can't exhaust memory.
+// truncated reports whether rows were left unread. The query is logged with
+// how long it took to run and read.
+func (a *App) queryRows(ctx context.Context, maxRows int, query string, args ...interface{}) ([]Column, [][]interface{}, bool, error) {
+	return a.queryRowsOn(ctx, a.conn(), maxRows, query, args...)
+}
+
+// queryRowsOn is queryRows on the connection pool db.
+func (a *App) queryRowsOn(ctx context.Context, db *sql.DB, maxRows int, query string, args ...interface{}) (columns []Column, results [][]interface{}, truncated bool, err error) {
+	start := time.Now()
+	defer func() { a.logQuery(query, args, time.Since(start)) }()
+	ctx, sp := a.traceQuery(ctx, query)
+	defer func() {
+		sp.fail(err)
+		sp.finish()
+	}()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+
+	columns, err = rowColumns(rows)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	for rows.Next() {
+		if maxRows > 0 && len(results) == maxRows {
+			truncated = true
+			break
+		}
+		values, err := a.scanRow(rows, columns)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		results = append(results, values)
+	}
+
+	return columns, results, truncated, rows.Err()
+}
+
+// rowColumns returns the columns of a result set.
+func rowColumns(rows *sql.Rows) ([]Column, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]Column, len(columnTypes))
+	for i, ct := range columnTypes {
+		columns[i] = Column{Name: ct.Name(), Type: ct.DatabaseTypeName()}
+	}
+	return columns, nil
+}
+
+// scanRow reads the current row of rows, converting its values for display.
+func (a *App) scanRow(rows *sql.Rows, columns []Column) ([]interface{}, error) {
+	// Create a slice of empty interfaces to scan into
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	// Convert byte slices (BLOBs) and other types to printable strings
+	for i, val := range values {
+		if b, ok := val.([]byte); ok {
+			val = string(b)
+			values[i] = val
+		}
+		switch v := val.(type) {
+		case string:
+			if isDateType(columns[i].Type) {
+				if t, ok := parseTime(v); ok {
+					values[i] = t.Format(a.timeFormat)
+				}
+			}
+		case time.Time:
+			values[i] = v.Format(a.timeFormat)
+		case nil:
+			values[i] = nullValue{}
+		}
+	}
+	return values, nil
+}
+
+// nullValue stands for SQL NULL in scanned rows. It prints and encodes to
+// JSON as "NULL", as pages and the API show it, while formats that tell NULL
+// apart from text, like CSV, can still recognize it.
+type nullValue struct{}
+
+// String returns "NULL", which templates show.
+func (nullValue) String() string {
+	return "NULL"
+}
+
+// MarshalJSON encodes NULL as the string "NULL".
+func (nullValue) MarshalJSON() ([]byte, error) {
+	return []byte(`"NULL"`), nil
+}
+
+// --- Helper Functions ---
+
+// isBodyTooLarge reports whether err came from reading past the request body
+// limit set by limitBodies.
+func isBodyTooLarge(err error) bool {
+	// http.MaxBytesError needs Go 1.19, so match the error text instead.
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
+
+// allowMethods reports whether the request uses one of the given methods,
+// counting HEAD as GET. Otherwise it sets the Allow header and the caller
+// should respond with 405 Method Not Allowed.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	allowed := make([]string, 0, len(methods)+1)
+	for _, m := range methods {
+		if r.Method == m || (m == http.MethodGet && r.Method == http.MethodHead) {
+			return true
+		}
+		allowed = append(allowed, m)
+		if m == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	return false
+}
+
+// splitTableRoute splits the part of an escaped URL path that follows
+// "/table/" or "/api/table/" into the URL-decoded table name and sub-resource
+// path after it, e.g. "Order%20Details/row/42" becomes ("Order Details",
+// "row/42"). Splitting before decoding lets table names contain "%2F".
+func splitTableRoute(escapedPath string) (tableName, subpath string, err error) {
+	escapedName, escapedSubpath, _ := strings.Cut(escapedPath, "/")
+	if tableName, err = url.PathUnescape(escapedName); err != nil {
+		return "", "", err
+	}
+	if subpath, err = url.PathUnescape(escapedSubpath); err != nil {
+		return "", "", err
+	}
+	return tableName, subpath, nil
+}
+
+// sourceTable returns the table a simple single-table SELECT reads from, or an
+// empty string if the query is too complex to attribute to one table.
+func sourceTable(query string) string {
+	m := sourceTableRe.FindStringSubmatch(query)
+	if m == nil {
+		return ""
+	}
+	name := m[1]
+	switch name[0] {
+	case '"':
+		name = strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+	case '[', '`':
+		name = name[1 : len(name)-1]
+	}
+	return name
+}
+
+// isNumericType reports whether a declared column type has INTEGER, REAL or
+// NUMERIC affinity, following the rules in https://www.sqlite.org/datatype3.html.
+func isNumericType(declType string) bool {
+	t := strings.ToUpper(declType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return true
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return false
+	case t == "", strings.Contains(t, "BLOB"):
+		return false
+	default:
+		return true // REAL, FLOAT, DOUBLE, NUMERIC, DECIMAL, BOOLEAN, DATE...
+	}
+}
+
+// isTextType reports whether a declared column type has TEXT affinity, or no
+// declared type at all (such columns usually hold text too). These are the
+// columns searched by ?_search=.
+func isTextType(declType string) bool {
+	t := strings.ToUpper(declType)
+	return t == "" || strings.Contains(t, "CHAR") || strings.Contains(t, "CLOB") || strings.Contains(t, "TEXT")
+}
+
+// highlight wraps each occurrence of term in a text column's value in <mark>
+// tags for the HTML table view. Matching ignores ASCII case only, like SQLite's
+// LIKE. The value is HTML-escaped; values of other columns, and all values
+// when term is empty, are returned unchanged for the template to escape.
+func highlight(value interface{}, term, declType string) interface{} {
+	s, ok := value.(string)
+	if !ok || term == "" || !isTextType(declType) {
+		return value
+	}
+	// asciiLower keeps byte offsets intact, so indexes into the lowered
+	// strings are valid in the originals.
+	lower, needle := asciiLower(s), asciiLower(term)
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, needle)
+		if i < 0 {
+			break
+		}
+		b.WriteString(template.HTMLEscapeString(s[:i]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(s[i : i+len(needle)]))
+		b.WriteString("</mark>")
+		s, lower = s[i+len(needle):], lower[i+len(needle):]
+	}
+	b.WriteString(template.HTMLEscapeString(s))
+	return template.HTML(b.String())
+}
+
+// emailRe matches values the "email" render hint links: one @ and nothing
+// that would need escaping in a mailto: URL.
+var emailRe = regexp.MustCompile(`^[^\s@<>"'?&:/]+@[^\s@<>"'?&:/]+$`)
+
+// renderCell formats a table cell like highlight, then links it according to
+// its column's render hint: http and https URLs for "url", email addresses for
+// "email". JSON objects and arrays in text columns are shown collapsed, see
+// renderJSON. Other values are shown as plain text.
+func renderCell(value interface{}, term, declType, hint string) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return highlight(value, term, declType)
+	}
+	var href string
+	switch lower := strings.ToLower(s); {
+	case hint == renderURL && (strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")):
+		href = s
+	case hint == renderEmail && emailRe.MatchString(s):
+		href = "mailto:" + s
+	case isTextType(declType) && isJSONContainer(s):
+		return renderJSON(s, term, declType)
+	default:
+		return highlight(value, term, declType)
+	}
+	text, ok := highlight(value, term, declType).(template.HTML)
+	if !ok {
+		text = template.HTML(template.HTMLEscapeString(s))
+	}
+	return template.HTML(`<a href="` + template.HTMLEscapeString(href) + `" class="text-indigo-600 dark:text-indigo-400 hover:text-indigo-900" rel="noopener noreferrer">` + string(text) + `</a>`)
+}
+
+// asciiLower lowercases the ASCII letters in s, leaving other bytes alone.
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// quoteIdent quotes an SQL identifier such as a table or column name, doubling
+// any embedded double quotes as SQLite requires. Unlike Go's %q verb, this
+// cannot be broken out of by a name containing quotes or backslashes.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// randomParams parses the sample size (?n=) and method (?method=rowid|order)
+// for the random row endpoints.
+func randomParams(r *http.Request) (n int, method string, err error) {
+	params := newQueryParams(r)
+	n = params.int("n", defaultRandomRows, 1, maxRandomRows)
+	method = params.oneOf("method", "rowid", "rowid", "order")
+	return n, method, params.err()
+}
+
+// pageCount returns the number of pages needed to show totalRows rows.
+func pageCount(totalRows int64) int {
+	if totalRows <= 0 {
+		return 0
+	}
+	return int((totalRows + rowsPerPage - 1) / rowsPerPage)
+}
+
+// numberRows returns rows with a leading "#" column holding each row's
+// position in the view, counting from offset+1. It is the position in the
+// current sort order, not the rowid. rows are copied, since they may be
+// shared with the query cache.
+func numberRows(columns []Column, rows [][]interface{}, offset int) ([]Column, [][]interface{}) {
+	numbered := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		numbered[i] = append([]interface{}{int64(offset + i + 1)}, row...)
+	}
+	return append([]Column{{Name: "#", Type: "INTEGER"}}, columns...), numbered
+}
+
+// clampPage limits page to [1, totalPages]. An empty table still has a
+// (blank) first page.
+func clampPage(page, totalPages int) int {
+	if page > totalPages {
+		page = totalPages
+	}
+	if page < 1 {
+		page = 1
+	}
+	return page
+}
+
+// isDateType reports whether a declared column type looks like a date or time,
+// e.g. DATE, DATETIME, TIMESTAMP or "TIMESTAMP WITH TIME ZONE". SQLite has no
+// native date type, so the declared type is the only hint available.
+func isDateType(declType string) bool {
+	t := strings.ToUpper(declType)
+	return strings.Contains(t, "DATE") || strings.Contains(t, "TIME")
+}
+
+// parseTime parses s using the first matching layout in timeLayouts.
+func parseTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// escapeLike escapes the LIKE wildcards in s using backslash as the escape
+// character, so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// themeFromRequest returns the color theme chosen via the theme cookie, or
+// "system" to follow the browser's prefers-color-scheme setting.
+func themeFromRequest(r *http.Request) string {
+	if c, err := r.Cookie(themeCookie); err == nil && (c.Value == "light" || c.Value == "dark") {
+		return c.Value
+	}
+	return "system"
+}
+
+// wantsJSON reports whether the client prefers a JSON response, based on its
+// Accept header.
+func wantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+// columnNames returns just the names of the given columns.
+func columnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// columnTypes returns the declared types of a table's result columns, in the
+// same order, as PRAGMA table_xinfo gives them, cached with the rest of the
+// table's schema. The rowid selected as rowidColumn is always "INTEGER".
+func (a *App) columnTypes(ctx context.Context, tableName string, columns []Column) ([]string, error) {
+	info, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	declared := make(map[string]string, len(info))
+	for _, col := range info {
+		declared[col.Name] = col.Type
+	}
+	types := make([]string, len(columns))
+	for i, col := range columns {
+		typ, ok := declared[col.Name]
+		switch {
+		case ok:
+		case col.Name == rowidColumn:
+			typ = "INTEGER"
+		default:
+			typ = col.Type // e.g. a column added by a RowTransformer
+		}
+		types[i] = typ
+	}
+	return types, nil
+}
+
+func (a *App) renderTemplate(w http.ResponseWriter, r *http.Request, tmplName string, data PageData) {
+	data.Page = tmplName
+	data.Theme = themeFromRequest(r)
+	data.CustomQuery = !a.noCustomQuery
+	data.IndexURL = a.indexURL()
+	if data.CurrentTable != "" && data.ColumnViews == nil {
+		data.ColumnViews = a.columnViews(data.CurrentTable, data.Columns)
+		for i := range data.ColumnViews {
+			data.ColumnViews[i].Frozen = data.Frozen != "" && data.ColumnViews[i].Name == data.Frozen
+		}
+	}
+	err := a.templates.ExecuteTemplate(w, tmplName, data)
+	if err != nil {
+		log.Printf("Error executing template %s: %v", tmplName, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// renderError reports a failed request to the client, as JSON when the client
+// accepts it and as the HTML error page otherwise. Any underlying err is logged
+// and only exposed to the client in debug mode, so SQLite internals don't leak.
+func (a *App) renderError(w http.ResponseWriter, r *http.Request, code int, message string, err error) {
+	message = a.errorMessage(r, code, message, err)
+	if wantsJSON(r) {
+		a.respondWithError(w, code, message)
+		return
+	}
+
+	data := PageData{
+		DBName:      a.displayName(),
+		Error:       message,
+		ErrorStatus: code,
+	}
+	w.WriteHeader(code)
+	a.renderTemplate(w, r, "error.html", data)
+}
+
+// errorMessage logs err, if any, and returns the message to show the client:
+// the bare message normally, or the message with err appended in debug mode.
+func (a *App) errorMessage(r *http.Request, code int, message string, err error) string {
+	if err == nil {
+		return message
+	}
+	log.Printf("level=error client=%s method=%s path=%q status=%d msg=%q err=%q", a.clientIP(r), r.Method, r.URL.Path, code, message, err)
+	if a.debug {
+		return fmt.Sprintf("%s: %v", message, err)
+	}
+	return message
+}
+
+// respondWithInternalError logs err and responds with a JSON 500 error.
+func (a *App) respondWithInternalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	a.respondWithError(w, http.StatusInternalServerError, a.errorMessage(r, http.StatusInternalServerError, message, err))
+}
+
+func (a *App) respondWithError(w http.ResponseWriter, code int, message string) {
+	a.respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+func (a *App) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, err := marshalJSON(w, payload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Failed to marshal JSON response"}`))
+		return
+	}
+	code, response = errorEnvelope(w, code, response)
+	writeJSON(w, code, "application/json", response)
+}
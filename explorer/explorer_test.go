@@ -0,0 +1,51 @@
+// explorer_test.go
+package explorer
+
+import (
+	"database/sql"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Every query is logged; only show them with -v.
+	flag.Parse()
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestApp creates a database in a temporary directory, runs schema on it,
+// and returns an App serving it with cfg. The App is closed when the test
+// ends.
+func newTestApp(t *testing.T, schema string, cfg Config) *App {
+	t.Helper()
+	cfg.DBPath = newTestDB(t, "test.db", schema)
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.Close() })
+	return app
+}
+
+// newTestDB creates the database file name in a temporary directory, runs
+// schema on it, and returns its path.
+func newTestDB(t *testing.T, name, schema string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	db, err := sql.Open(DefaultDriver, "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("creating test database: %v", err)
+	}
+	return path
+}
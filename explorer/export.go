@@ -0,0 +1,113 @@
+// export.go
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExportTable writes every row of tableName, a table of the main database,
+// to w in format, as ExecQuery does. It backs -export-table.
+func (a *App) ExportTable(ctx context.Context, w io.Writer, tableName, format string) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+	exists, err := a.tableExists(ctx, tableName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no such table: %s", tableName)
+	}
+	return a.exportTable(ctx, w, "SELECT * FROM "+quoteIdent(tableName), tableName, format)
+}
+
+// ExportAll writes every table listed on the index page, including those of
+// attached databases, to its own file in dir, which is created if needed.
+// Files are named after the table and the format, e.g. users.csv, with
+// tables of attached databases prefixed by the database, e.g.
+// archive.users.csv. Tables whose files would have the same name, like a
+// table "archive.users" next to the users table of database archive, are
+// reported before anything is written. It stops at the first table that
+// fails. It backs -export-all.
+func (a *App) ExportAll(ctx context.Context, dir, format string) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+	tables, _, err := a.getTables(ctx, "", 0, 0)
+	if err != nil {
+		return err
+	}
+	type export struct{ qualified, name, path string }
+	exports := make([]export, 0, len(tables))
+	taken := make(map[string]string, len(tables))
+	for _, table := range tables {
+		qualified, name := quoteIdent(table.Name), table.Name
+		if table.Schema != "main" {
+			qualified = quoteIdent(table.Schema) + "." + qualified
+			name = table.Schema + "." + name
+		}
+		file := exportFileName(name) + "." + format
+		desc := fmt.Sprintf("table %q of database %s", table.Name, table.Schema)
+		// Compare case-insensitively, as some file systems do.
+		if other, ok := taken[strings.ToLower(file)]; ok {
+			return fmt.Errorf("%s and %s would both be exported to %s", other, desc, file)
+		}
+		taken[strings.ToLower(file)] = desc
+		exports = append(exports, export{qualified, name, filepath.Join(dir, file)})
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, e := range exports {
+		err := CreateFile(e.path, func(w io.Writer) error {
+			return a.exportTable(ctx, w, "SELECT * FROM "+e.qualified, e.name, format)
+		})
+		if err != nil {
+			return fmt.Errorf("exporting %s: %v", e.name, err)
+		}
+		log.Printf("Exported %s to %s", e.name, e.path)
+	}
+	return nil
+}
+
+// exportTable runs query, which selects all of tableName, and writes the
+// rows to w in format as they are read.
+func (a *App) exportTable(ctx context.Context, w io.Writer, query, tableName, format string) error {
+	return a.writeQuery(ctx, w, format, tableName, query)
+}
+
+// CreateFile creates the file path and writes it with write. If write fails,
+// the partly written file is removed, so a failed export doesn't leave a
+// truncated file behind for a later job to pick up.
+func CreateFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// exportFileName replaces the characters of name that can't appear in a
+// file name.
+func exportFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,87 @@
+// funcblock.go
+package explorer
+
+import (
+	"strings"
+)
+
+// DefaultBlockedFunctions are the SQL functions custom queries may not call
+// unless Config.BlockedFunctions says otherwise: those that reach outside the
+// database, such as loading extensions or reading and writing files, when the
+// SQLite build provides them, and fts3_tokenizer, which can be abused to call
+// arbitrary code.
+var DefaultBlockedFunctions = []string{
+	"load_extension",
+	"readfile",
+	"writefile",
+	"edit",
+	"fts3_tokenizer",
+	"zipfile",
+	"sqlar_uncompress",
+}
+
+// blockedFunctionSet returns names, lowercased, as a set.
+func blockedFunctionSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
+
+// blockedFunction returns the first function called by query that is on the
+// blocklist, or "" if there is none.
+func (a *App) blockedFunction(query string) string {
+	for _, name := range calledFunctions(query) {
+		if a.blockedFunctions[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+// calledFunctions returns the lowercased names that query calls as functions,
+// i.e. that are followed by "(". It is a heuristic tokenizer rather than a
+// parser: it skips string literals and comments and unquotes quoted names, but
+// also reports keywords followed by "(", like IN or VALUES, which are harmless
+// when matched against a blocklist.
+func calledFunctions(query string) []string {
+	var (
+		names []string
+		name  string // Name just read, a call if "(" follows
+	)
+	for i := 0; i < len(query); {
+		if next, ok := skipComment(query, i); ok {
+			i = next
+			continue
+		}
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '(':
+			if name != "" {
+				names = append(names, strings.ToLower(name))
+			}
+			name = ""
+			i++
+		case c == '\'':
+			_, i = readQuoted(query, i, '\'')
+			name = ""
+		case c == '"' || c == '`':
+			name, i = readQuoted(query, i, c)
+		case c == '[':
+			name, i = readQuoted(query, i, ']')
+		case isNameByte(c):
+			start := i
+			for i < len(query) && isNameByte(query[i]) {
+				i++
+			}
+			name = query[start:i]
+		default:
+			name = ""
+			i++
+		}
+	}
+	return names
+}
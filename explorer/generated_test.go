@@ -0,0 +1,120 @@
+// generated_test.go
+package explorer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const generatedSchema = `
+CREATE TABLE items (
+	id INTEGER PRIMARY KEY,
+	price REAL,
+	qty INTEGER,
+	total REAL GENERATED ALWAYS AS (price * qty) VIRTUAL,
+	label TEXT GENERATED ALWAYS AS ('item ' || id) STORED
+);
+INSERT INTO items (id, price, qty) VALUES (1, 2.5, 4), (2, 1, 3);
+`
+
+// namedColumn decodes just the name of a column from an API response.
+type namedColumn struct {
+	Name string `json:"name"`
+}
+
+// columnNamesOf returns the names of columns, comma-separated.
+func columnNamesOf(columns []namedColumn) string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestGeneratedColumnsSchema(t *testing.T) {
+	app := newTestApp(t, generatedSchema, Config{})
+	var resp struct {
+		Columns []string        `json:"columns"`
+		Rows    [][]interface{} `json:"rows"`
+		Schema  []ColumnInfo    `json:"schema"`
+	}
+	getJSON(t, app, "/api/table/items?_schema=on", http.StatusOK, &resp)
+
+	// SELECT * reads generated columns, computed for each row.
+	if got := strings.Join(resp.Columns, ","); got != "id,price,qty,total,label" {
+		t.Errorf("columns = %s, want the generated ones too", got)
+	}
+	if len(resp.Rows) != 2 || resp.Rows[0][3] != 10.0 || resp.Rows[0][4] != "item 1" {
+		t.Errorf("rows = %v, want total 10 and label \"item 1\" first", resp.Rows)
+	}
+
+	want := []struct {
+		name      string
+		hidden    int
+		generated string
+	}{
+		{"id", columnOrdinary, ""},
+		{"price", columnOrdinary, ""},
+		{"qty", columnOrdinary, ""},
+		{"total", columnGeneratedVirtual, "virtual"},
+		{"label", columnGeneratedStored, "stored"},
+	}
+	if len(resp.Schema) != len(want) {
+		t.Fatalf("schema = %+v, want %d columns", resp.Schema, len(want))
+	}
+	for i, w := range want {
+		col := resp.Schema[i]
+		if col.Name != w.name || col.Hidden != w.hidden || col.Generated != w.generated {
+			t.Errorf("schema[%d] = %s hidden %d %q, want %s hidden %d %q", i, col.Name, col.Hidden, col.Generated, w.name, w.hidden, w.generated)
+		}
+	}
+
+	// Type inference samples them like any other column.
+	var infer struct {
+		Columns []namedColumn `json:"columns"`
+	}
+	getJSON(t, app, "/api/table/items/infer", http.StatusOK, &infer)
+	if got := columnNamesOf(infer.Columns); got != "id,price,qty,total,label" {
+		t.Errorf("inferred columns = %s, want all five", got)
+	}
+
+	// A generated text column is searched like a stored one.
+	getJSON(t, app, "/api/table/items?_search="+url.QueryEscape("item 2"), http.StatusOK, &resp)
+	if len(resp.Rows) != 1 || resp.Rows[0][0] != 2.0 {
+		t.Errorf("search for \"item 2\" = %v, want row 2", resp.Rows)
+	}
+}
+
+func TestGeneratedColumnsNotWritable(t *testing.T) {
+	app := newTestApp(t, generatedSchema, Config{Writable: true})
+
+	// The row template offers only the columns an insert can set.
+	var template struct {
+		Columns []namedColumn `json:"columns"`
+	}
+	getJSON(t, app, "/api/table/items/template", http.StatusOK, &template)
+	if got := columnNamesOf(template.Columns); got != "id,price,qty" {
+		t.Errorf("template columns = %s, want id,price,qty", got)
+	}
+
+	// Imports that set a generated column are refused as a whole.
+	for _, body := range []string{"price,qty,total\n3,2,99\n", "id,label\n9,x\n"} {
+		rec := serve(app, http.MethodPost, "/api/table/items/import", "text/csv", body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Generated columns can't be imported") {
+			t.Errorf("importing %q = %d %s, want 400 naming the generated column", body, rec.Code, rec.Body)
+		}
+	}
+
+	// Imports of the other columns get the generated ones computed.
+	rec := serve(app, http.MethodPost, "/api/table/items/import", "text/csv", "id,price,qty\n3,3,2\n")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import = %d %s", rec.Code, rec.Body)
+	}
+	rows := apiQueryRows(t, app, "/api/query?sql="+url.QueryEscape("SELECT total, label FROM items WHERE id = 3"))
+	if fmt.Sprint(rows) != "[[6 item 3]]" {
+		t.Errorf("imported row = %v, want total 6 and label \"item 3\"", rows)
+	}
+}
@@ -0,0 +1,105 @@
+// geojson.go
+package explorer
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Column names recognized as coordinates when -geo-lat-col and -geo-lng-col
+// aren't set, compared case-insensitively.
+var (
+	latColumnNames = []string{"latitude", "lat"}
+	lngColumnNames = []string{"longitude", "lng", "lon", "long"}
+)
+
+// geoColumns returns the indexes of the latitude and longitude columns, using
+// the configured names if set and the usual names otherwise.
+func (a *App) geoColumns(columns []Column) (lat, lng int, ok bool) {
+	latNames, lngNames := latColumnNames, lngColumnNames
+	if a.geoLatCol != "" {
+		latNames = []string{a.geoLatCol}
+	}
+	if a.geoLngCol != "" {
+		lngNames = []string{a.geoLngCol}
+	}
+	lat, lng = findColumn(columns, latNames), findColumn(columns, lngNames)
+	return lat, lng, lat >= 0 && lng >= 0
+}
+
+// findColumn returns the index of the first of names found among columns, or
+// -1 if there is none.
+func findColumn(columns []Column, names []string) int {
+	for _, name := range names {
+		for i, col := range columns {
+			if strings.EqualFold(col.Name, name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// respondWithGeoJSON writes rows as a GeoJSON FeatureCollection of points,
+// with the remaining columns as feature properties. Rows whose coordinates
+// are NULL or not numbers are skipped.
+func (a *App) respondWithGeoJSON(w http.ResponseWriter, columns []Column, rows [][]interface{}) {
+	latIdx, lngIdx, ok := a.geoColumns(columns)
+	if !ok {
+		a.respondWithError(w, http.StatusBadRequest, "No latitude/longitude columns found (set -geo-lat-col and -geo-lng-col)")
+		return
+	}
+
+	names := uniqueColumnNames(columns)
+	features := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		lat, latOK := coordinate(row[latIdx])
+		lng, lngOK := coordinate(row[lngIdx])
+		if !latOK || !lngOK {
+			continue
+		}
+		properties := make(map[string]interface{}, len(columns)-2)
+		for i, name := range names {
+			if i != latIdx && i != lngIdx {
+				properties[name] = row[i]
+			}
+		}
+		features = append(features, map[string]interface{}{
+			"type": "Feature",
+			"geometry": map[string]interface{}{
+				"type":        "Point",
+				"coordinates": []float64{lng, lat}, // GeoJSON puts longitude first
+			},
+			"properties": properties,
+		})
+	}
+
+	response, err := marshalJSON(w, map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+	if err != nil {
+		a.respondWithError(w, http.StatusInternalServerError, "Failed to marshal GeoJSON response")
+		return
+	}
+	writeJSON(w, http.StatusOK, "application/geo+json", response)
+}
+
+// coordinate converts a column value to a float. NULLs reach here as
+// nullValue, and like any other non-number have no coordinate.
+func coordinate(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case nullValue:
+		return 0, false
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,192 @@
+// glob.go
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAttached is how many databases SQLite can attach to one connection
+// (SQLITE_MAX_ATTACHED); -db-glob matches beyond it are skipped.
+const maxAttached = 10
+
+// schemaNameInvalidRe matches runs of characters not allowed in schema names.
+var schemaNameInvalidRe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// WatchDBGlob rescans the -db-glob pattern every interval, if it is
+// positive, and whenever a value arrives on rescan (e.g. SIGHUP), until ctx
+// is done. New matching files are attached and files that no longer match
+// are detached, closing their handles. It does nothing for Apps without a
+// glob or created by NewAppWithDB. Run it in its own goroutine.
+func (a *App) WatchDBGlob(ctx context.Context, interval time.Duration, rescan <-chan os.Signal) {
+	if a.dbGlob == "" || !a.ownsDB {
+		return
+	}
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-rescan:
+		}
+		if err := a.rescanDBGlob(); err != nil {
+			log.Printf("Could not rescan %s: %v", a.dbGlob, err)
+		}
+	}
+}
+
+// rescanDBGlob brings the attached databases in line with the files matching
+// the -db-glob pattern, reopening the connection pool if they changed. The
+// old pool, and with it the handles of detached files, is closed by
+// replaceDB.
+func (a *App) rescanDBGlob() error {
+	a.reopenMu.Lock()
+	defer a.reopenMu.Unlock()
+
+	current := a.attachments()
+	attached, err := globAttachments(a.dbGlob, a.globFixed, current, a.dbPath, a.dbName)
+	if err != nil {
+		return err
+	}
+	if sameAttachments(current, attached) {
+		return nil
+	}
+	db, err := openDB(a.driver, a.dbPath, a.writable, a.dsnParams, attached)
+	if err != nil {
+		return err
+	}
+	a.replaceDB(db, attached)
+
+	was := make(map[attachedDB]bool, len(current))
+	for _, att := range current {
+		was[att] = true
+	}
+	for _, att := range attached {
+		if !was[att] {
+			log.Printf("Attached %s as %s", att.Path, att.Name)
+		}
+		delete(was, att)
+	}
+	for att := range was {
+		log.Printf("Detached %s (%s)", att.Path, att.Name)
+	}
+	return nil
+}
+
+// globAttachments returns fixed followed by the files matching pattern as
+// attachments, leaving out the main database at mainPath and files already in
+// fixed. Files attached in previous keep their schema names, so their routes
+// stay the same across rescans. New files are named after the file like -db
+// files, with other characters replaced by underscores, and numbered if the
+// name is taken, e.g. "sales_2" for a second sales.db in another directory.
+// Compressed files are skipped, as they can't be attached in place.
+func globAttachments(pattern string, fixed, previous []attachedDB, mainPath, mainName string) ([]attachedDB, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database glob %q: %w", pattern, err)
+	}
+
+	// Schema names are case-insensitive in SQLite.
+	taken := map[string]bool{"main": true, "temp": true, strings.ToLower(mainName): true}
+	skip := map[string]bool{absPath(mainPath): true}
+	for _, att := range fixed {
+		taken[strings.ToLower(att.Name)] = true
+		skip[absPath(att.Path)] = true
+	}
+	var paths []string
+	for _, path := range matches {
+		if skip[absPath(path)] {
+			continue
+		}
+		gzipped, err := isGzipped(path)
+		if err != nil {
+			continue // Removed since the glob, or not readable
+		}
+		if gzipped {
+			log.Printf("Not attaching %s: compressed databases can't be attached by -db-glob", path)
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	// Keep the names of files that are already attached before naming new ones.
+	names := make(map[string]string, len(paths))
+	for _, att := range previous {
+		names[att.Path] = att.Name
+	}
+	for _, path := range paths {
+		if name, ok := names[path]; ok {
+			taken[strings.ToLower(name)] = true
+		}
+	}
+
+	attached := append([]attachedDB{}, fixed...)
+	for _, path := range paths {
+		if len(attached) == maxAttached {
+			log.Printf("Not attaching %s: SQLite can attach at most %d databases", path, maxAttached)
+			continue
+		}
+		name, ok := names[path]
+		if !ok {
+			name = uniqueSchemaName(globSchemaName(path), taken)
+			taken[strings.ToLower(name)] = true
+		}
+		attached = append(attached, attachedDB{Name: name, Path: path})
+	}
+	return attached, nil
+}
+
+// globSchemaName derives a schema name from a file name, e.g. "shop_2024"
+// for "/data/shop-2024.db" and "_2024" for "2024.db".
+func globSchemaName(path string) string {
+	name := schemaNameInvalidRe.ReplaceAllString(baseName(path), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// uniqueSchemaName returns name, or if taken has it (in lower case) the first
+// of name_2, name_3, ... that taken doesn't.
+func uniqueSchemaName(name string, taken map[string]bool) string {
+	unique := name
+	for n := 2; taken[strings.ToLower(unique)]; n++ {
+		unique = name + "_" + strconv.Itoa(n)
+	}
+	return unique
+}
+
+// sameAttachments reports whether a and b attach the same files under the
+// same names, in the same order.
+func sameAttachments(a, b []attachedDB) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// absPath returns path made absolute, or path itself if that fails.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
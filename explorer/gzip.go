@@ -0,0 +1,82 @@
+// gzip.go
+package explorer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gzipMagic are the first bytes of every gzip file.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isGzipped reports whether the file at path is gzip-compressed, going by its
+// contents rather than its name.
+func isGzipped(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	header := make([]byte, len(gzipMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil // Too short to be gzip; let SQLite judge it
+		}
+		return false, err
+	}
+	return bytes.Equal(header, gzipMagic), nil
+}
+
+// decompressDB decompresses the gzipped database at path into a temporary
+// file and returns that file's path. The caller removes the file when done.
+func decompressDB(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	out, err := os.CreateTemp("", "godatasette-*.db")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, zr); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// openablePath returns the path SQLite should open for the database file at
+// path: path itself, or a decompressed temporary copy if the file is gzipped,
+// in which case temp is true.
+func openablePath(path string) (openPath string, temp bool, err error) {
+	gzipped, err := isGzipped(path)
+	if err != nil || !gzipped {
+		return path, false, err
+	}
+	openPath, err = decompressDB(path)
+	return openPath, err == nil, err
+}
+
+// baseName returns the file name of path without its extension, ignoring a
+// ".gz" suffix first, e.g. "sales" for "/data/sales.db.gz".
+func baseName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".gz")
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
@@ -0,0 +1,53 @@
+// health.go
+package explorer
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// healthPingTimeout bounds each ping, so a hung file system shows up as a
+// failure instead of stalling the checks.
+const healthPingTimeout = 10 * time.Second
+
+// HealthPing pings the database every interval until ctx is done, logging
+// failures, so a connection that died silently (e.g. on a network file
+// system) is noticed before a request runs into it. With reopen set, a failed
+// ping also reopens the database file, as -watch-db does when the file is
+// replaced; Apps created by NewAppWithDB and gzipped databases are only
+// pinged. Run it in its own goroutine; it returns once ctx is done.
+func (a *App) HealthPing(ctx context.Context, interval time.Duration, reopen bool) {
+	reopen = reopen && a.ownsDB && !a.compressed
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+		err := a.conn().PingContext(pingCtx)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		log.Printf("Database health ping failed: %v", err)
+		if !reopen {
+			continue
+		}
+		a.reopenMu.Lock()
+		attached := a.attachments()
+		db, err := openDB(a.driver, a.dbPath, a.writable, a.dsnParams, attached)
+		if err != nil {
+			a.reopenMu.Unlock()
+			log.Printf("Database could not be reopened after a failed health ping: %v", err)
+			continue
+		}
+		a.replaceDB(db, attached)
+		a.reopenMu.Unlock()
+		log.Printf("Reconnected to %s after a failed health ping", a.dbPath)
+	}
+}
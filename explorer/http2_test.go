@@ -0,0 +1,124 @@
+// http2_test.go
+package explorer
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const http2Schema = `
+CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT);
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 2000)
+INSERT INTO t SELECT i, 'row ' || i FROM n;
+CREATE TABLE log (msg TEXT);
+`
+
+// http2Servers starts app behind HTTP/2 over TLS and behind h2c, as main
+// serves it with -tls-cert and with -h2c, and returns a client for each.
+func http2Servers(t *testing.T, app *App) map[string]func() (string, *http.Client) {
+	return map[string]func() (string, *http.Client){
+		"tls": func() (string, *http.Client) {
+			srv := httptest.NewUnstartedServer(app.Handler())
+			srv.EnableHTTP2 = true
+			srv.StartTLS()
+			t.Cleanup(srv.Close)
+			return srv.URL, srv.Client()
+		},
+		"h2c": func() (string, *http.Client) {
+			srv := httptest.NewServer(h2c.NewHandler(app.Handler(), &http2.Server{}))
+			t.Cleanup(srv.Close)
+			client := &http.Client{Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, addr)
+				},
+			}}
+			return srv.URL, client
+		},
+	}
+}
+
+// TestHTTP2StreamedCSV checks that a CSV response too large to buffer is
+// streamed whole over HTTP/2.
+func TestHTTP2StreamedCSV(t *testing.T) {
+	app := newTestApp(t, http2Schema, Config{StreamThreshold: 1024})
+	for name, start := range http2Servers(t, app) {
+		t.Run(name, func(t *testing.T) {
+			url, client := start()
+			resp, err := client.Get(url + "/api/table/t?_all=on&_format=csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+				t.Fatalf("got %s %s, want HTTP/2 200", resp.Proto, resp.Status)
+			}
+			if resp.ContentLength != -1 {
+				t.Errorf("Content-Length = %d, want a streamed response", resp.ContentLength)
+			}
+			lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+			if len(lines) != 2001 || strings.TrimSpace(lines[2000]) != "2000,row 2000" {
+				t.Errorf("got %d lines ending %q, want the header and 2000 rows", len(lines), lines[len(lines)-1])
+			}
+		})
+	}
+}
+
+// TestHTTP2Tail checks that the tail stream sends each event as soon as its
+// row is added over HTTP/2, rather than holding it in a buffer.
+func TestHTTP2Tail(t *testing.T) {
+	app := newTestApp(t, http2Schema, Config{TailInterval: 20 * time.Millisecond})
+	writer, err := sql.Open(DefaultDriver, "file:"+app.dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	for name, start := range http2Servers(t, app) {
+		t.Run(name, func(t *testing.T) {
+			url, client := start()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/table/log/tail", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.ProtoMajor != 2 || resp.Header.Get("Content-Type") != "text/event-stream" {
+				t.Fatalf("got %s %q, want an HTTP/2 event stream", resp.Proto, resp.Header.Get("Content-Type"))
+			}
+
+			msg := "hello over " + name
+			if _, err := writer.Exec("INSERT INTO log VALUES (?)", msg); err != nil {
+				t.Fatal(err)
+			}
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if strings.HasPrefix(scanner.Text(), "data:") && strings.Contains(scanner.Text(), msg) {
+					return
+				}
+			}
+			t.Fatalf("stream ended before the new row: %v", scanner.Err())
+		})
+	}
+}
@@ -0,0 +1,336 @@
+// import.go
+package explorer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxImportErrors caps how many per-row errors are reported back to the client.
+const maxImportErrors = 100
+
+// importRecord is a single row to import. Line is the 1-based CSV line number,
+// or the 1-based array index for JSON bodies.
+type importRecord struct {
+	Line   int
+	Values []interface{}
+}
+
+// importError describes a row that could not be inserted.
+type importError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// handleAPIImport inserts rows from a CSV or JSON request body into a table.
+// Columns are matched by header (CSV) or key (JSON) name. With ?create=1 a
+// missing table is created, inferring column types from the data.
+func (a *App) handleAPIImport(w http.ResponseWriter, r *http.Request, tableName string) {
+	if !allowMethods(w, r, http.MethodPost) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !a.writable {
+		a.respondWithError(w, http.StatusForbidden, "Imports require the server to run with -writable")
+		return
+	}
+	if tableName == "" {
+		a.respondWithError(w, http.StatusBadRequest, "Table name not specified")
+		return
+	}
+	params := newQueryParams(r)
+	create := params.oneOf("create", "0", "1", "true", "0", "false")
+	if err := params.err(); err != nil {
+		a.respondWithParamErrors(w, err)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var (
+		headers []string
+		records []importRecord
+		err     error
+	)
+	switch mediaType {
+	case "text/csv":
+		headers, records, err = readCSVImport(r.Body)
+	case "application/json":
+		headers, records, err = readJSONImport(r.Body)
+	default:
+		a.respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be text/csv or application/json")
+		return
+	}
+	if isBodyTooLarge(err) {
+		a.respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	if err != nil {
+		a.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Failed to parse request body: %v", err))
+		return
+	}
+	if len(headers) == 0 {
+		a.respondWithError(w, http.StatusBadRequest, "Request body has no columns")
+		return
+	}
+	if dup := duplicateHeader(headers); dup != "" {
+		a.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Duplicate column '%s' in request body", dup))
+		return
+	}
+
+	exists, err := a.tableExists(r.Context(), tableName)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to look up table", err)
+		return
+	}
+	created := false
+	switch {
+	case !exists && (create == "1" || create == "true"):
+		created = true
+	case !exists:
+		a.respondWithError(w, http.StatusNotFound, "Table not found (use ?create=1 to create it)")
+		return
+	default:
+		columns, err := a.tableInfo(r.Context(), tableName)
+		if err != nil {
+			a.respondWithInternalError(w, r, "Failed to get table schema", err)
+			return
+		}
+		known := make(map[string]bool, len(columns))
+		generated := make(map[string]bool)
+		for _, col := range columns {
+			known[col.Name] = true
+			generated[col.Name] = col.isGenerated()
+		}
+		var unknown, computed []string
+		for _, h := range headers {
+			if !known[h] {
+				unknown = append(unknown, h)
+			} else if generated[h] {
+				computed = append(computed, h)
+			}
+		}
+		if len(unknown) > 0 {
+			a.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown columns: %s", strings.Join(unknown, ", ")))
+			return
+		}
+		if len(computed) > 0 {
+			a.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Generated columns can't be imported: %s", strings.Join(computed, ", ")))
+			return
+		}
+	}
+
+	inserted, failed, rowErrors, err := a.importRows(r.Context(), tableName, headers, records, created)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Import failed", err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"tableName": tableName,
+		"created":   created,
+		"inserted":  inserted,
+		"failed":    failed,
+		"errors":    rowErrors,
+	}
+	a.respondWithJSON(w, http.StatusOK, response)
+}
+
+// importRows inserts records into tableName inside a single transaction,
+// creating the table first if requested. Rows that fail (e.g. on a constraint)
+// are skipped and reported rather than aborting the whole import.
+func (a *App) importRows(ctx context.Context, tableName string, headers []string, records []importRecord, create bool) (inserted, failed int, rowErrors []importError, err error) {
+	tx, err := a.conn().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer tx.Rollback()
+
+	quoted := make([]string, len(headers))
+	placeholders := make([]string, len(headers))
+	for i, h := range headers {
+		quoted[i] = quoteIdent(h)
+		placeholders[i] = "?"
+	}
+
+	if create {
+		defs := make([]string, len(headers))
+		for i := range headers {
+			defs[i] = quoted[i] + " " + inferColumnType(records, i)
+		}
+		ddl := fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdent(tableName), strings.Join(defs, ", "))
+		if _, err := tx.Exec(ddl); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(tableName), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer stmt.Close()
+
+	rowErrors = []importError{}
+	for _, rec := range records {
+		if _, err := stmt.Exec(rec.Values...); err != nil {
+			failed++
+			if len(rowErrors) < maxImportErrors {
+				rowErrors = append(rowErrors, importError{Line: rec.Line, Error: err.Error()})
+			}
+			continue
+		}
+		inserted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, nil, err
+	}
+	return inserted, failed, rowErrors, nil
+}
+
+// duplicateHeader returns the first column name that appears more than once
+// in headers, or "" if there is none. SQLite compares column names without
+// regard to ASCII case, so "id" and "ID" are the same column.
+func duplicateHeader(headers []string) string {
+	seen := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		key := asciiLower(h)
+		if seen[key] {
+			return h
+		}
+		seen[key] = true
+	}
+	return ""
+}
+
+// readCSVImport reads a CSV body whose first line holds the column names.
+// Every field is imported as a string and left to SQLite's type affinity.
+func readCSVImport(body io.Reader) ([]string, []importRecord, error) {
+	reader := csv.NewReader(body)
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []importRecord
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		values := make([]interface{}, len(fields))
+		for i, f := range fields {
+			values[i] = f
+		}
+		records = append(records, importRecord{Line: line, Values: values})
+	}
+	return headers, records, nil
+}
+
+// readJSONImport reads a JSON array of objects. The column set is the sorted
+// union of all keys; keys missing from an object are imported as NULL.
+func readJSONImport(body io.Reader) ([]string, []importRecord, error) {
+	var objects []map[string]interface{}
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, nil, err
+	}
+
+	var headers []string
+	seen := make(map[string]bool)
+	for _, obj := range objects {
+		for key := range obj {
+			if !seen[key] {
+				seen[key] = true
+				headers = append(headers, key)
+			}
+		}
+	}
+	sort.Strings(headers)
+
+	records := make([]importRecord, len(objects))
+	for i, obj := range objects {
+		values := make([]interface{}, len(headers))
+		for j, h := range headers {
+			values[j] = jsonImportValue(obj[h])
+		}
+		records[i] = importRecord{Line: i + 1, Values: values}
+	}
+	return headers, records, nil
+}
+
+// jsonImportValue converts a decoded JSON value into something SQLite can
+// store. Nested objects and arrays are stored as their JSON text.
+func jsonImportValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return v // string or nil
+	}
+}
+
+// inferColumnType picks INTEGER, REAL or TEXT for column i based on all
+// non-empty values in records. Columns with no values default to TEXT.
+func inferColumnType(records []importRecord, i int) string {
+	colType := ""
+	for _, rec := range records {
+		var kind string
+		switch v := rec.Values[i].(type) {
+		case nil:
+			continue
+		case int, int64:
+			kind = "INTEGER"
+		case float64:
+			kind = "REAL"
+		case string:
+			if v == "" {
+				continue
+			}
+			if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+				kind = "INTEGER"
+			} else if _, err := strconv.ParseFloat(v, 64); err == nil {
+				kind = "REAL"
+			} else {
+				return "TEXT"
+			}
+		default:
+			return "TEXT"
+		}
+		if colType == "" || (colType == "INTEGER" && kind == "REAL") {
+			colType = kind
+		}
+	}
+	if colType == "" {
+		return "TEXT"
+	}
+	return colType
+}
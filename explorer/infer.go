@@ -0,0 +1,111 @@
+// infer.go
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sample sizes for /api/table/{name}/infer.
+const (
+	defaultInferSample = 1000
+	maxInferSample     = 100000
+)
+
+// storageClasses are the values SQLite's typeof() returns.
+var storageClasses = []string{"integer", "real", "text", "blob", "null"}
+
+// ColumnTypes reports the storage classes found in a sample of one column's
+// values, which with SQLite's dynamic typing can differ from its declared
+// type.
+type ColumnTypes struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`        // Declared type
+	Counts      map[string]int     `json:"counts"`      // Values of each storage class
+	Proportions map[string]float64 `json:"proportions"` // Share of the sample of each storage class, 0 to 1
+}
+
+// handleAPIInferTypes samples up to ?n= rows of a table and reports each
+// column's storage classes.
+func (a *App) handleAPIInferTypes(w http.ResponseWriter, r *http.Request, tableName string) {
+	params := newQueryParams(r)
+	n := params.int("n", defaultInferSample, 1, maxInferSample)
+	if err := params.err(); err != nil {
+		a.respondWithParamErrors(w, err)
+		return
+	}
+
+	sampled, columns, err := a.inferTypes(r.Context(), tableName, n)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to sample table", err)
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"tableName": tableName,
+		"sampled":   sampled,
+		"columns":   columns,
+	})
+}
+
+// inferTypes reads typeof() of every column for the first n rows of
+// tableName in a single pass, and returns how many rows were read and the
+// storage classes of each column.
+func (a *App) inferTypes(ctx context.Context, tableName string, n int) (int, []ColumnTypes, error) {
+	info, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return 0, nil, err
+	}
+	columns := make([]ColumnTypes, 0, len(info))
+	selects := make([]string, 0, len(info))
+	for _, col := range info {
+		if col.Hidden == columnHidden {
+			continue
+		}
+		columns = append(columns, ColumnTypes{Name: col.Name, Type: col.Type, Counts: map[string]int{}, Proportions: map[string]float64{}})
+		selects = append(selects, "typeof("+quoteIdent(col.Name)+")")
+	}
+	if len(columns) == 0 {
+		return 0, columns, nil
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s LIMIT ?", strings.Join(selects, ", "), quoteIdent(tableName))
+	rows, err := a.conn().QueryContext(ctx, query, n)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	types := make([]string, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range types {
+		ptrs[i] = &types[i]
+	}
+	sampled := 0
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, nil, err
+		}
+		for i, t := range types {
+			columns[i].Counts[t]++
+		}
+		sampled++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	// Every storage class gets an entry, zero if it wasn't seen.
+	for _, col := range columns {
+		for _, class := range storageClasses {
+			count := col.Counts[class]
+			col.Counts[class] = count
+			col.Proportions[class] = 0
+			if sampled > 0 {
+				col.Proportions[class] = float64(count) / float64(sampled)
+			}
+		}
+	}
+	return sampled, columns, nil
+}
@@ -0,0 +1,104 @@
+// jsoncols.go
+package explorer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonPreviewLength is how many characters of a JSON value the table view
+// shows before it is expanded.
+const jsonPreviewLength = 60
+
+// isJSONContainer reports whether s holds a JSON object or array. Other JSON
+// values, like numbers or quoted strings, are left alone, since text such as
+// "123" is more likely meant as text than as JSON.
+func isJSONContainer(s string) bool {
+	t := strings.TrimSpace(s)
+	if t == "" || (t[0] != '{' && t[0] != '[') {
+		return false
+	}
+	return json.Valid([]byte(t))
+}
+
+// renderJSON shows a JSON object or array collapsed to its first characters,
+// expanding to the pretty-printed value, with term highlighted in it.
+func renderJSON(s, term, declType string) template.HTML {
+	var pretty bytes.Buffer
+	json.Indent(&pretty, []byte(strings.TrimSpace(s)), "", "  ") // s is valid JSON, see isJSONContainer
+	body, ok := highlight(pretty.String(), term, declType).(template.HTML)
+	if !ok {
+		body = template.HTML(template.HTMLEscapeString(pretty.String()))
+	}
+	preview := strings.TrimSpace(s)
+	if utf8.RuneCountInString(preview) > jsonPreviewLength {
+		preview = string([]rune(preview)[:jsonPreviewLength]) + "…"
+	}
+	return template.HTML(`<details class="json-value"><summary class="cursor-pointer">` + template.HTMLEscapeString(preview) +
+		`</summary><pre class="mt-2 whitespace-pre text-xs">` + string(body) + `</pre></details>`)
+}
+
+// jsonColumnsParam reads ?_json_cols=, the columns of tableName whose JSON
+// values the API returns parsed rather than as strings. It fails params for
+// unknown columns, and for formats other than JSON and GeoJSON, which can't
+// hold nested values.
+func (a *App) jsonColumnsParam(r *http.Request, params *queryParams, tableName, format string) ([]string, error) {
+	cols := splitColumnsParam(params.values["_json_cols"])
+	if len(cols) == 0 {
+		return nil, nil
+	}
+	err := a.checkColumns(r.Context(), tableName, cols)
+	var unknownCols *unknownColumnsError
+	if errors.As(err, &unknownCols) {
+		params.fail("_json_cols", err.Error())
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if format != "json" && format != "geojson" {
+		params.fail("_json_cols", "_json_cols needs _format=json or geojson")
+	}
+	return cols, nil
+}
+
+// parseJSONColumns returns rows with the JSON objects and arrays in the named
+// columns decoded, so they are sent as nested values. Other values, including
+// text that isn't JSON, are unchanged. rows are copied, since they may be
+// shared with the query cache.
+func parseJSONColumns(columns []Column, rows [][]interface{}, names []string) [][]interface{} {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var indexes []int
+	for i, col := range columns {
+		if wanted[col.Name] {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return rows
+	}
+	out := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		out[i] = append([]interface{}(nil), row...)
+		for _, j := range indexes {
+			s, ok := row[j].(string)
+			if !ok || !isJSONContainer(s) {
+				continue
+			}
+			decoder := json.NewDecoder(strings.NewReader(s))
+			decoder.UseNumber()
+			var v interface{}
+			if err := decoder.Decode(&v); err == nil {
+				out[i][j] = v
+			}
+		}
+	}
+	return out
+}
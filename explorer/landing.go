@@ -0,0 +1,51 @@
+// landing.go
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// defaultRouteAuto is the -default-route that lands on a database's table
+// when it has only one.
+const defaultRouteAuto = "auto"
+
+// indexPath is where the index is served, besides "/", so it stays reachable
+// when "/" redirects elsewhere.
+const indexPath = "/tables"
+
+// validateDefaultRoute checks a -default-route: empty, "auto" or a path
+// other than "/".
+func validateDefaultRoute(route string) error {
+	if route == "" || route == defaultRouteAuto {
+		return nil
+	}
+	if !strings.HasPrefix(route, "/") || route == "/" || strings.HasPrefix(route, "//") {
+		return fmt.Errorf("invalid default route %q: must be auto or a path like /table/users", route)
+	}
+	return nil
+}
+
+// landingRedirect returns where a request for "/" should be redirected, or
+// "" to show the index: -default-route if it is a path, or with "auto" the
+// only table of the main database, if it has exactly one.
+func (a *App) landingRedirect(ctx context.Context) (string, error) {
+	if a.defaultRoute != defaultRouteAuto {
+		return a.defaultRoute, nil
+	}
+	names, err := a.tableNames(ctx)
+	if err != nil || len(names) != 1 {
+		return "", err
+	}
+	return "/table/" + url.PathEscape(names[0]), nil
+}
+
+// indexURL returns the URL of the index, which pages link to.
+func (a *App) indexURL() string {
+	if a.defaultRoute != "" {
+		return indexPath
+	}
+	return "/"
+}
@@ -0,0 +1,144 @@
+// markdown.go
+package explorer
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Inline Markdown, matched against text that is already HTML-escaped.
+var (
+	mdHeadingRe = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	mdBulletRe  = regexp.MustCompile(`^[-*+]\s+`)
+	mdNumberRe  = regexp.MustCompile(`^\d+[.)]\s+`)
+	mdLinkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdStrongRe  = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdEmRe      = regexp.MustCompile(`\*([^*]+)\*`)
+)
+
+// loadDescription returns the index page description, rendered from the
+// Markdown in text or else in the file at path, or "" if neither is set.
+func loadDescription(text, path string) (template.HTML, error) {
+	if text != "" && path != "" {
+		return "", errors.New("set a description or a description file, not both")
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read description file: %w", err)
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", nil
+	}
+	return renderMarkdown(text), nil
+}
+
+// renderMarkdown converts a small, common subset of Markdown to HTML:
+// paragraphs, # headings on their own line, bulleted and numbered lists, **bold**, *italics*,
+// `code` and [links](url). Everything else is shown as text. The source is
+// HTML-escaped before anything is converted, so it can't inject markup, and
+// links only keep http, https and mailto URLs and relative ones.
+func renderMarkdown(src string) template.HTML {
+	var b strings.Builder
+	for _, block := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if len(lines) == 1 && strings.TrimSpace(lines[0]) == "" {
+			continue
+		}
+		if m := mdHeadingRe.FindStringSubmatch(lines[0]); m != nil {
+			// Page headings use h1 and h2, so descriptions start at h3.
+			level := len(m[1]) + 2
+			if level > 6 {
+				level = 6
+			}
+			tag := "h" + strconv.Itoa(level)
+			b.WriteString("<" + tag + ">" + markdownInline(m[2]) + "</" + tag + ">\n")
+			if lines = lines[1:]; len(lines) == 0 {
+				continue
+			}
+		}
+		if tag, items := markdownList(lines); tag != "" {
+			b.WriteString("<" + tag + ">\n")
+			for _, item := range items {
+				b.WriteString("<li>" + markdownInline(item) + "</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+			continue
+		}
+		for i := range lines {
+			lines[i] = strings.TrimSpace(lines[i])
+		}
+		b.WriteString("<p>" + markdownInline(strings.Join(lines, "\n")) + "</p>\n")
+	}
+	return template.HTML(b.String())
+}
+
+// markdownList returns the list tag, "ul" or "ol", and the items of a block
+// whose lines all start with a bullet or all with a number, or "" otherwise.
+func markdownList(lines []string) (tag string, items []string) {
+	for _, candidate := range []struct {
+		tag string
+		re  *regexp.Regexp
+	}{{"ul", mdBulletRe}, {"ol", mdNumberRe}} {
+		items = items[:0]
+		for _, line := range lines {
+			loc := candidate.re.FindStringIndex(line)
+			if loc == nil {
+				break
+			}
+			items = append(items, line[loc[1]:])
+		}
+		if len(items) == len(lines) {
+			return candidate.tag, items
+		}
+	}
+	return "", nil
+}
+
+// markdownInline escapes text and converts its inline Markdown. Code spans
+// are left exactly as written.
+func markdownInline(text string) string {
+	parts := strings.Split(text, "`")
+	for i, part := range parts {
+		part = html.EscapeString(part)
+		// Odd parts are between backticks; an unmatched last one isn't code.
+		if i%2 == 1 && i < len(parts)-1 {
+			parts[i] = "<code>" + part + "</code>"
+			continue
+		}
+		if i%2 == 1 {
+			part = "`" + part
+		}
+		part = mdLinkRe.ReplaceAllStringFunc(part, func(link string) string {
+			m := mdLinkRe.FindStringSubmatch(link)
+			if !safeLinkURL(html.UnescapeString(m[2])) {
+				return link
+			}
+			return `<a href="` + m[2] + `">` + m[1] + `</a>`
+		})
+		part = mdStrongRe.ReplaceAllString(part, "<strong>$1</strong>")
+		part = mdEmRe.ReplaceAllString(part, "<em>$1</em>")
+		parts[i] = part
+	}
+	return strings.Join(parts, "")
+}
+
+// safeLinkURL reports whether a Markdown link target may become an href:
+// http, https and mailto URLs, and relative ones like "/table/users".
+func safeLinkURL(u string) bool {
+	lower := strings.ToLower(u)
+	for _, scheme := range []string{"http://", "https://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return !strings.Contains(strings.SplitN(u, "/", 2)[0], ":")
+}
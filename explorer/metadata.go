@@ -0,0 +1,158 @@
+// metadata.go
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Metadata holds per-table settings read from the -metadata JSON file, e.g.
+//
+//	{"tables": {"events": {"sort_desc": "created_at"}}}
+type Metadata struct {
+	Tables map[string]TableMetadata `json:"tables"`
+}
+
+// TableMetadata holds the settings of one table. Sort and SortDesc name the
+// column the table view and API order rows by, ascending or descending; set
+// at most one of them. Columns holds display settings by column name. Freeze
+// names the column the table view keeps in view when scrolling sideways,
+// instead of the primary key.
+type TableMetadata struct {
+	Sort     string                    `json:"sort"`
+	SortDesc string                    `json:"sort_desc"`
+	Columns  map[string]ColumnMetadata `json:"columns"`
+	Freeze   string                    `json:"freeze"`
+}
+
+// ColumnMetadata holds how the table view and row pages show a column. They
+// head it with Label instead of its name if Label is set, and Render says how
+// to show its values: "url" links http and https URLs, "email" links email
+// addresses with mailto:, and "" or "text" shows them as plain text, with JSON
+// objects and arrays collapsed and pretty-printed. The API ignores both.
+type ColumnMetadata struct {
+	Label  string `json:"label"`
+	Render string `json:"render"`
+}
+
+// Render hints of ColumnMetadata.
+const (
+	renderText  = "text"
+	renderURL   = "url"
+	renderEmail = "email"
+)
+
+// ColumnView is how the table view and row pages show one column, from its
+// metadata.
+type ColumnView struct {
+	Name   string
+	Label  string // Heading, the column's name unless the metadata sets a label
+	Render string // Render hint, "text" unless the metadata sets one
+	Frozen bool   // Kept in view when the table scrolls sideways
+}
+
+// loadMetadata reads the metadata file at path, returning empty metadata if
+// path is empty.
+func loadMetadata(path string) (Metadata, error) {
+	var md Metadata
+	if path == "" {
+		return md, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return md, fmt.Errorf("failed to read metadata file: %w", err)
+	}
+	if err := json.Unmarshal(data, &md); err != nil {
+		return md, fmt.Errorf("failed to parse metadata file %s: %w", path, err)
+	}
+	for name, table := range md.Tables {
+		if table.Sort != "" && table.SortDesc != "" {
+			return md, fmt.Errorf("metadata for table %s sets both sort and sort_desc", name)
+		}
+		for col, column := range table.Columns {
+			switch column.Render {
+			case "", renderText, renderURL, renderEmail:
+			default:
+				return md, fmt.Errorf("metadata for column %s of table %s has unknown render %q: must be text, url or email", col, name, column.Render)
+			}
+		}
+	}
+	return md, nil
+}
+
+// checkMetadata logs a warning for each table, sort column or display column
+// named in the metadata that doesn't exist in the database, and drops a
+// missing sort column's default sort, so a stale metadata file can't break
+// the table views.
+func (a *App) checkMetadata(ctx context.Context) {
+	for name, table := range a.metadata.Tables {
+		col := table.Sort + table.SortDesc
+		if col == "" && len(table.Columns) == 0 && table.Freeze == "" {
+			continue
+		}
+		info, err := a.tableInfo(ctx, name)
+		if err != nil {
+			log.Printf("Warning: can't check metadata for table %s: %v", name, err)
+			continue
+		}
+		if len(info) == 0 {
+			log.Printf("Warning: metadata names table %s, which doesn't exist", name)
+			continue
+		}
+		exists := map[string]bool{}
+		for _, c := range info {
+			exists[c.Name] = true
+		}
+		for display := range table.Columns {
+			if !exists[display] {
+				log.Printf("Warning: metadata describes column %s of table %s, which doesn't exist", display, name)
+			}
+		}
+		if col != "" && !exists[col] {
+			log.Printf("Warning: metadata sorts table %s by column %s, which doesn't exist; ignoring it", name, col)
+			table.Sort, table.SortDesc = "", ""
+			a.metadata.Tables[name] = table
+		}
+		if table.Freeze != "" && !exists[table.Freeze] {
+			log.Printf("Warning: metadata freezes column %s of table %s, which doesn't exist; ignoring it", table.Freeze, name)
+			table.Freeze = ""
+			a.metadata.Tables[name] = table
+		}
+	}
+}
+
+// defaultOrder returns the ORDER BY clause for tableName's default sort from
+// the metadata, or "" if it has none.
+func (a *App) defaultOrder(tableName string) string {
+	table := a.metadata.Tables[tableName]
+	switch {
+	case table.Sort != "":
+		return " ORDER BY " + quoteIdent(table.Sort)
+	case table.SortDesc != "":
+		return " ORDER BY " + quoteIdent(table.SortDesc) + " DESC"
+	}
+	return ""
+}
+
+// columnViews returns how to show each of columns, which were read from
+// tableName, following the table's column metadata.
+func (a *App) columnViews(tableName string, columns []Column) []ColumnView {
+	settings := a.metadata.Tables[tableName].Columns
+	views := make([]ColumnView, len(columns))
+	for i, col := range columns {
+		view := ColumnView{Name: col.Name, Label: col.Name, Render: renderText}
+		if md, ok := settings[col.Name]; ok {
+			if md.Label != "" {
+				view.Label = md.Label
+			}
+			if md.Render != "" {
+				view.Render = md.Render
+			}
+		}
+		views[i] = view
+	}
+	return views
+}
@@ -0,0 +1,86 @@
+// methods_test.go
+package explorer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMethodNotAllowed checks that each route rejects the methods it doesn't
+// serve with 405 and an Allow header listing the ones it does.
+func TestMethodNotAllowed(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO users VALUES (1, 'a');", Config{
+		Name:          "test",
+		Writable:      true,
+		AllowDownload: true,
+		Admin:         true,
+		AdminToken:    "secret",
+	})
+	const getOnly, postOnly, getOrPost = "GET, HEAD", "POST", "GET, HEAD, POST"
+	tests := []struct {
+		path  string
+		allow string
+	}{
+		{"/", getOnly},
+		{indexPath, getOnly},
+		{"/table/users", getOnly},
+		{"/table/users/row/1", getOnly},
+		{"/table/users/tail", getOnly},
+		{"/theme", postOnly},
+		{"/metrics", getOnly},
+		{"/api/tables", getOnly},
+		{"/api/table/users", getOnly},
+		{"/api/table/users/count", getOnly},
+		{"/api/table/users/template", getOnly},
+		{"/api/table/users/import", postOnly},
+		{"/api/download.db", getOnly},
+		{"/api/version", getOnly},
+		{"/api/relationships", getOnly},
+		{"/api/summary", getOnly},
+		{"/api/explain", getOnly},
+		{"/api/batch", postOnly},
+		{"/query", getOrPost},
+		{"/db/test/query", getOrPost},
+		{"/api/query", getOrPost},
+		{"/api/db/test/query", getOrPost},
+		{"/api/admin/integrity", getOnly},
+		{"/api/admin/queries", getOnly},
+		{"/api/admin/queries/1", "DELETE"},
+	}
+	for _, tt := range tests {
+		allowed := map[string]bool{}
+		for _, m := range strings.Split(tt.allow, ", ") {
+			allowed[m] = true
+		}
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			if allowed[method] {
+				continue
+			}
+			req := httptest.NewRequest(method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			app.Handler().ServeHTTP(rec, req)
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s = %d, want 405", method, tt.path, rec.Code)
+				continue
+			}
+			if allow := rec.Header().Get("Allow"); allow != tt.allow {
+				t.Errorf("%s %s: Allow = %q, want %q", method, tt.path, allow, tt.allow)
+			}
+		}
+	}
+}
+
+// TestHeadAllowed checks that HEAD is served wherever GET is.
+func TestHeadAllowed(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE users (id INTEGER PRIMARY KEY)", Config{})
+	for _, path := range []string{"/", "/table/users", "/api/tables", "/api/table/users", "/api/version"} {
+		rec := httptest.NewRecorder()
+		app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("HEAD %s = %d, want 200", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// pagination.go
+package explorer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// deepOffset is the row offset from which a table page counts as "deep". SQLite
+// implements OFFSET by stepping over every skipped row, so deep pages cost time
+// proportional to their offset; -deep-page-mode decides how they are handled.
+const deepOffset = 100000
+
+// Deep page modes accepted by -deep-page-mode.
+const (
+	deepPageWarn  = "warn"  // Log a warning and run the OFFSET query anyway
+	deepPageError = "error" // Refuse with 400, pointing clients at ?_after=
+	deepPageRowid = "rowid" // Seek by rowid where possible, else as "warn"
+)
+
+// errDeepPage is returned by getTableData for deep pages in "error" mode.
+var errDeepPage = fmt.Errorf("pages beyond row %d are disabled on this server; use ?_after=<rowid> to page by rowid instead", deepOffset)
+
+// errNoRowid is returned by getTableDataAfter for WITHOUT ROWID tables.
+var errNoRowid = errors.New("table has no rowid; use ?page= instead of ?_after=")
+
+// validDeepPageMode reports whether mode is a known -deep-page-mode value.
+func validDeepPageMode(mode string) bool {
+	return mode == deepPageWarn || mode == deepPageError || mode == deepPageRowid
+}
+
+// deepPage handles a page whose offset is at least deepOffset according to
+// the server's deep page mode, returning the query for it. ok is false if the
+// caller should fall back to a plain OFFSET query.
+func (a *App) deepPage(ctx context.Context, tableName string, offset int, view tableView) (query string, args []interface{}, ok bool, err error) {
+	log.Printf("Deep page requested for table %s at offset %d (-deep-page-mode=%s)", tableName, offset, a.deepPageMode)
+	switch a.deepPageMode {
+	case deepPageError:
+		return "", nil, true, errDeepPage
+	case deepPageRowid:
+		if view.Search != "" || a.orderBy(tableName, view) != "" {
+			// Matching rows aren't contiguous in rowid order, and sorted
+			// pages aren't in rowid order at all.
+			return "", nil, false, nil
+		}
+		return a.seekPage(ctx, tableName, offset, view)
+	}
+	return "", nil, false, nil
+}
+
+// seekPage returns a query for the page starting at offset that seeks to a
+// computed rowid rather than stepping over the preceding rows. This is only
+// correct when the table's rowids are contiguous (no deleted rows or gaps), so
+// ok is false otherwise, and for tables without a rowid.
+func (a *App) seekPage(ctx context.Context, tableName string, offset int, view tableView) (query string, args []interface{}, ok bool, err error) {
+	var (
+		minID, maxID sql.NullInt64
+		count        int64
+	)
+	rangeQuery := fmt.Sprintf("SELECT MIN(rowid), MAX(rowid), COUNT(*) FROM %s", quoteIdent(tableName))
+	if err := a.conn().QueryRowContext(ctx, rangeQuery).Scan(&minID, &maxID, &count); err != nil {
+		if strings.Contains(err.Error(), "no such column: rowid") {
+			return "", nil, false, nil
+		}
+		return "", nil, false, err
+	}
+	if !minID.Valid || maxID.Int64-minID.Int64+1 != count {
+		return "", nil, false, nil
+	}
+
+	query = fmt.Sprintf("SELECT %s FROM %s WHERE rowid >= ? ORDER BY rowid LIMIT %d", view.selectList(), quoteIdent(tableName), rowsPerPage)
+	return query, []interface{}{minID.Int64 + int64(offset)}, true, nil
+}
+
+// getTableDataAfter retrieves the page of rows whose rowid follows after, in
+// rowid order, shaped by view. Unlike page numbers this stays fast however far
+// into the table it is. next is the cursor for the following page, or nil
+// after the last one.
+func (a *App) getTableDataAfter(ctx context.Context, tableName string, after int64, view tableView) (columns []Column, rows [][]interface{}, next interface{}, err error) {
+	where, args, err := a.searchFilter(ctx, tableName, view.Search)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if where == "" {
+		where = " WHERE rowid > ?"
+	} else {
+		where += " AND rowid > ?"
+	}
+	args = append(args, after)
+
+	// Select the rowid alongside the row for the next cursor; it is stripped
+	// from the results below.
+	query := fmt.Sprintf("SELECT rowid, %s FROM %s%s ORDER BY rowid LIMIT %d", view.selectList(), quoteIdent(tableName), where, rowsPerPage)
+	columns, rows, err = a.executeCustomQuery(ctx, query, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such column: rowid") {
+			err = errNoRowid
+		}
+		return nil, nil, nil, err
+	}
+	if len(rows) == rowsPerPage {
+		next = rows[len(rows)-1][0]
+	}
+	for i := range rows {
+		rows[i] = rows[i][1:]
+	}
+	return columns[1:], rows, next, nil
+}
+
+// pageWindowRadius is how many pages either side of the current one the
+// numbered pager links to.
+const pageWindowRadius = 2
+
+// PageLink is one entry of the numbered pager below a table. Ellipsis entries
+// stand for a run of skipped pages and have no URL.
+type PageLink struct {
+	Number   int
+	URL      string
+	Current  bool
+	Ellipsis bool
+}
+
+// pageLinks builds the numbered pager for page of totalPages: the first and
+// last pages plus a window around the current one, with ellipses for gaps.
+// Links keep the request's other query parameters, such as _search.
+func pageLinks(query url.Values, page, totalPages int) []PageLink {
+	var links []PageLink
+	last := 0
+	for n := 1; n <= totalPages; n++ {
+		if n != 1 && n != totalPages && (n < page-pageWindowRadius || n > page+pageWindowRadius) {
+			continue
+		}
+		if n > last+1 {
+			links = append(links, PageLink{Ellipsis: true})
+		}
+		links = append(links, PageLink{Number: n, URL: pageURL(query, n), Current: n == page})
+		last = n
+	}
+	return links
+}
+
+// pageURL returns a relative URL for page n that keeps the other parameters
+// of query.
+func pageURL(query url.Values, n int) string {
+	q := make(url.Values, len(query))
+	for key, values := range query {
+		q[key] = values
+	}
+	q.Set("page", strconv.Itoa(n))
+	return "?" + q.Encode()
+}
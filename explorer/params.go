@@ -0,0 +1,131 @@
+// params.go
+package explorer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ParamError describes one invalid request parameter.
+type ParamError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// paramErrors lists every invalid parameter of a request.
+type paramErrors []ParamError
+
+func (e paramErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// queryParams reads a request's query parameters. Rather than stopping at the
+// first invalid one, or quietly falling back to a default, it records an error
+// for each, so clients learn about all of them at once from err.
+type queryParams struct {
+	values url.Values
+	errs   paramErrors
+}
+
+func newQueryParams(r *http.Request) *queryParams {
+	return &queryParams{values: r.URL.Query()}
+}
+
+// get returns the value of name, or "" if it is absent.
+func (p *queryParams) get(name string) string {
+	return p.values.Get(name)
+}
+
+// int returns the integer value of name, or def if it is absent or empty.
+// Values that aren't integers or lie outside [min, max] are errors; a max of
+// 0 means no upper bound.
+func (p *queryParams) int(name string, def, min, max int) int {
+	v := p.values.Get(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || (max > 0 && n > max) {
+		p.fail(name, rangeMessage(name, min, max))
+		return def
+	}
+	return n
+}
+
+// int64 returns the integer value of name and whether it was given.
+func (p *queryParams) int64(name string) (int64, bool) {
+	v := p.values.Get(name)
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		p.fail(name, fmt.Sprintf("%s must be an integer", name))
+		return 0, false
+	}
+	return n, true
+}
+
+// oneOf returns the value of name, or def if it is absent or empty. Values
+// other than those allowed are errors.
+func (p *queryParams) oneOf(name, def string, allowed ...string) string {
+	v := p.values.Get(name)
+	if v == "" {
+		return def
+	}
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	p.fail(name, fmt.Sprintf("%s must be '%s'", name, strings.Join(allowed, "' or '")))
+	return def
+}
+
+// fail records an error for name found by the caller's own checks.
+func (p *queryParams) fail(name, message string) {
+	p.errs = append(p.errs, ParamError{Param: name, Message: message})
+}
+
+// err returns the recorded errors, or nil if all parameters were valid.
+func (p *queryParams) err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return p.errs
+}
+
+// rangeMessage describes the valid range of an integer parameter.
+func rangeMessage(name string, min, max int) string {
+	if max > 0 {
+		return fmt.Sprintf("%s must be between %d and %d", name, min, max)
+	}
+	switch min {
+	case 0:
+		return fmt.Sprintf("%s must be a non-negative integer", name)
+	case 1:
+		return fmt.Sprintf("%s must be a positive integer", name)
+	}
+	return fmt.Sprintf("%s must be an integer of at least %d", name, min)
+}
+
+// respondWithParamErrors responds with 400 and the errors from
+// queryParams.err, both combined in "error" and listed in "errors".
+func (a *App) respondWithParamErrors(w http.ResponseWriter, err error) {
+	errs, ok := err.(paramErrors)
+	if !ok {
+		a.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"error":  errs.Error(),
+		"errors": errs,
+	})
+}
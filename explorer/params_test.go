@@ -0,0 +1,72 @@
+// params_test.go
+package explorer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestInvalidParams checks that parameters with values they don't take are
+// reported as errors rather than read as if absent.
+func TestInvalidParams(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE t (a TEXT); INSERT INTO t VALUES ('x');", Config{Writable: true})
+	tests := []struct {
+		method, target string
+		param          string
+	}{
+		{http.MethodGet, "/api/table/t?_schema=yes", "_schema"},
+		{http.MethodGet, "/api/table/t?_schema=1", "_schema"},
+		{http.MethodPost, "/api/table/new/import?create=yes", "create"},
+		{http.MethodPost, "/api/table/new/import?create=on", "create"},
+	}
+	for _, tt := range tests {
+		rec := serve(app, tt.method, tt.target, "text/csv", "a\n1\n")
+		var resp struct {
+			Errors []ParamError `json:"errors"`
+		}
+		if rec.Code != http.StatusBadRequest || json.Unmarshal(rec.Body.Bytes(), &resp) != nil {
+			t.Errorf("%s %s = %d %s, want 400 with a list of errors", tt.method, tt.target, rec.Code, rec.Body)
+			continue
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0].Param != tt.param {
+			t.Errorf("%s %s errors = %+v, want one for %s", tt.method, tt.target, resp.Errors, tt.param)
+		}
+	}
+
+	var data struct {
+		Schema []ColumnInfo `json:"schema"`
+	}
+	getJSON(t, app, "/api/table/t?_schema=on", http.StatusOK, &data)
+	if len(data.Schema) != 1 {
+		t.Errorf("_schema=on gave schema %+v, want column a", data.Schema)
+	}
+	getJSON(t, app, "/api/table/t?_schema=off", http.StatusOK, &data)
+
+	for _, create := range []string{"1", "true"} {
+		rec := serve(app, http.MethodPost, "/api/table/new_"+create+"/import?create="+create, "text/csv", "a\n1\n")
+		if rec.Code != http.StatusOK {
+			t.Errorf("import with create=%s = %d %s", create, rec.Code, rec.Body)
+		}
+	}
+	for _, create := range []string{"0", "false"} {
+		rec := serve(app, http.MethodPost, "/api/table/missing/import?create="+create, "text/csv", "a\n1\n")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("import with create=%s into a missing table = %d, want 404", create, rec.Code)
+		}
+	}
+
+	rec := serve(app, http.MethodGet, "/table/t/tail?after=last", "", "")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "after must be an integer") {
+		t.Errorf("tail with after=last = %d %.200s, want 400", rec.Code, rec.Body)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/table/t/tail", nil)
+	req.Header.Set("Last-Event-ID", "last")
+	rec = httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Last-Event-ID must be an integer") {
+		t.Errorf("tail with Last-Event-ID: last = %d %.200s, want 400", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,131 @@
+// permalink.go
+package explorer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// permalink returns a URL showing the same rows as page of tableName, keyed
+// on the rowids of its first and last rows rather than the page number, so
+// the rows don't shift as others are inserted or deleted. It returns "" when
+// the page can't be pinned that way: for tables without a rowid, sorted pages
+// (which aren't in rowid order), and empty pages.
+func (a *App) permalink(ctx context.Context, tableName string, page int, view tableView) (string, error) {
+	if a.orderBy(tableName, view) != "" {
+		return "", nil
+	}
+	// Select just the rowids of the page; a quoted "rowid" still means the
+	// rowid unless the table has a real column of that name.
+	pageQuery, args, err := a.tablePageQuery(ctx, tableName, page, tableView{Search: view.Search, Columns: []string{"rowid"}})
+	if err != nil {
+		return "", err
+	}
+	var start, end sql.NullInt64
+	query := fmt.Sprintf("SELECT MIN(rowid), MAX(rowid) FROM (%s)", pageQuery)
+	if err := a.conn().QueryRowContext(ctx, query, args...).Scan(&start, &end); err != nil {
+		if strings.Contains(err.Error(), "no such column: rowid") {
+			return "", nil
+		}
+		return "", err
+	}
+	if !start.Valid {
+		return "", nil
+	}
+	params := url.Values{}
+	params.Set("_start", strconv.FormatInt(start.Int64, 10))
+	params.Set("_end", strconv.FormatInt(end.Int64, 10))
+	if view.Search != "" {
+		params.Set("_search", view.Search)
+	}
+	if len(view.Columns) > 0 {
+		// The selection may have come from the sharer's cookie, which the
+		// link's recipient doesn't have.
+		params.Set("_cols", strings.Join(view.Columns, ","))
+	}
+	if view.Rowid {
+		params.Set("_rowid", "on")
+	}
+	return "/table/" + url.PathEscape(tableName) + "?" + params.Encode(), nil
+}
+
+// rangeQuery builds the query for the rows of tableName with a rowid from
+// start to end inclusive, in rowid order, shaped by view. Like a page it
+// returns at most rowsPerPage rows.
+func (a *App) rangeQuery(ctx context.Context, tableName string, start, end int64, view tableView) (string, []interface{}, error) {
+	where, args, err := a.searchFilter(ctx, tableName, view.Search)
+	if err != nil {
+		return "", nil, err
+	}
+	if where == "" {
+		where = " WHERE rowid BETWEEN ? AND ?"
+	} else {
+		where += " AND rowid BETWEEN ? AND ?"
+	}
+	args = append(args, start, end)
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY rowid LIMIT %d", view.selectList(), quoteIdent(tableName), where, rowsPerPage)
+	return query, args, nil
+}
+
+// pinnedRange reads the _start and _end parameters of a permalink. pinned is
+// false when _start is absent; a missing _end leaves the range open-ended.
+func pinnedRange(params *queryParams) (start, end int64, pinned bool) {
+	start, pinned = params.int64("_start")
+	end, hasEnd := params.int64("_end")
+	switch {
+	case hasEnd && !pinned:
+		params.fail("_end", "_end requires _start")
+	case !hasEnd:
+		end = math.MaxInt64
+	case end < start:
+		params.fail("_end", "_end must not be less than _start")
+	}
+	return start, end, pinned
+}
+
+// handlePinnedRows renders the table view for a permalink: the rows of
+// tableName with a rowid from start to end, without the pager.
+func (a *App) handlePinnedRows(w http.ResponseWriter, r *http.Request, tableName string, start, end int64, view tableView) {
+	dataQuery, args, err := a.rangeQuery(r.Context(), tableName, start, end, view)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table data", err)
+		return
+	}
+	ctx, done := a.trackQuery(r, dataQuery)
+	defer done()
+	columns, stream, linked, err := a.streamTable(ctx, tableName, dataQuery, args...)
+	if err != nil && strings.Contains(err.Error(), "no such column: rowid") {
+		err = errNoRowid
+	}
+	if errors.Is(err, errNoRowid) {
+		a.renderError(w, r, http.StatusBadRequest, "Only tables with a rowid have permalinks", nil)
+		return
+	}
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to fetch table data", err)
+		return
+	}
+
+	a.renderTemplate(w, r, "table.html", PageData{
+		DBName:       a.displayName(),
+		CurrentTable: tableName,
+		Columns:      columns,
+		RowStream:    stream,
+		RowsLinked:   linked,
+		Search:       view.Search,
+		Pinned:       true,
+		Permalink:    r.URL.RequestURI(),
+		HasRowid:     true,
+		ShowRowid:    view.Rowid,
+
+		ColumnChoices: a.columnChoices(r.Context(), tableName, view.Columns),
+		Frozen:        view.Freeze,
+	})
+}
@@ -0,0 +1,81 @@
+// permalink_test.go
+package explorer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const permalinkSchema = `
+CREATE TABLE t (a TEXT, b TEXT, c TEXT);
+INSERT INTO t VALUES ('a-one', 'b-one', 'c-one'), ('a-two', 'b-two', 'c-two');
+`
+
+var permalinkRe = regexp.MustCompile(`<a href="([^"]*)" data-copy-link`)
+
+func TestPermalinkKeepsView(t *testing.T) {
+	app := newTestApp(t, permalinkSchema, Config{})
+	tests := []struct {
+		name string
+		view tableView
+		want url.Values
+	}{
+		{"plain", tableView{}, url.Values{"_start": {"1"}, "_end": {"2"}}},
+		{"search", tableView{Search: "two"}, url.Values{"_start": {"2"}, "_end": {"2"}, "_search": {"two"}}},
+		{"columns", tableView{Columns: []string{"a", "c"}}, url.Values{"_start": {"1"}, "_end": {"2"}, "_cols": {"a,c"}}},
+		{"rowid", tableView{Rowid: true}, url.Values{"_start": {"1"}, "_end": {"2"}, "_rowid": {"on"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := app.permalink(context.Background(), "t", 1, tt.view)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "/table/t?" + tt.want.Encode(); link != want {
+				t.Errorf("permalink() = %q, want %q", link, want)
+			}
+		})
+	}
+}
+
+// TestSharedPermalinkColumns checks that a permalink copied from a page whose
+// columns were chosen earlier, and so come from a cookie, shows the same
+// columns to someone without that cookie.
+func TestSharedPermalinkColumns(t *testing.T) {
+	app := newTestApp(t, permalinkSchema, Config{})
+	get := func(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		app.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d: %s", target, rec.Code, rec.Body)
+		}
+		return rec
+	}
+
+	cookies := get("/table/t?_cols=a,c").Result().Cookies()
+	page := get("/table/t", cookies...).Body.String()
+	m := permalinkRe.FindStringSubmatch(page)
+	if m == nil {
+		t.Fatal("table page has no permalink")
+	}
+	link := strings.ReplaceAll(m[1], "&amp;", "&")
+
+	shared := get(link).Body.String()
+	for _, want := range []string{"a-one", "c-two"} {
+		if !strings.Contains(shared, want) {
+			t.Errorf("permalink %s lacks %q from a selected column", link, want)
+		}
+	}
+	if strings.Contains(shared, "b-one") {
+		t.Errorf("permalink %s shows column b, which the sharer had hidden", link)
+	}
+}
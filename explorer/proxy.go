@@ -0,0 +1,105 @@
+// proxy.go
+package explorer
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies parses -trusted-proxies entries, each a CIDR range or a
+// single IP address.
+func parseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: not an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// isTrustedProxy reports whether ip belongs to one of the trusted proxies.
+func (a *App) isTrustedProxy(ip net.IP) bool {
+	for _, ipNet := range a.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that made r. Forwarding headers
+// are only believed when the direct peer is a trusted proxy, since anyone can
+// send them. X-Forwarded-For is read from the right, skipping the trusted
+// proxies that appended to it, so the first untrusted address is the client;
+// entries further left were supplied by the client and could be forged.
+// X-Real-IP is used when X-Forwarded-For is absent.
+func (a *App) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil || !a.isTrustedProxy(peer) {
+		return host
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break // Can't trust anything past a malformed entry
+			}
+			if !a.isTrustedProxy(ip) || i == 0 {
+				return ip.String()
+			}
+		}
+		return host
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+// requestOrigin returns the scheme and host r was sent to, such as
+// "https://data.example.com", for building absolute URLs. As with clientIP,
+// the X-Forwarded-Proto and X-Forwarded-Host headers are only believed from
+// trusted proxies.
+func (a *App) requestOrigin(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	peerHost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peerHost = r.RemoteAddr
+	}
+	if peer := net.ParseIP(peerHost); peer != nil && a.isTrustedProxy(peer) {
+		proto := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0])
+		if proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Host"), ",")[0]); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme + "://" + host
+}
@@ -0,0 +1,102 @@
+// querylength_test.go
+package explorer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// queryOfLength returns a SELECT exactly n characters long, padded with fill.
+func queryOfLength(n int, fill string) string {
+	const head, tail = "SELECT '", "'"
+	return head + strings.Repeat(fill, n-len(head)-len(tail)) + tail
+}
+
+// serve sends method target to app with body, if any, as contentType.
+func serve(app *App, method, target, contentType, body string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMaxQueryLength(t *testing.T) {
+	const limit = 40
+	app := newTestApp(t, "CREATE TABLE t (a);", Config{MaxQueryLength: limit})
+	const want = "sql must be at most 40 characters long"
+
+	for _, tt := range []struct {
+		name  string
+		query string
+		ok    bool
+	}{
+		{"at the limit", queryOfLength(limit, "x"), true},
+		{"one over", queryOfLength(limit+1, "x"), false},
+		{"far over", queryOfLength(10*limit, "x"), false},
+		// The limit counts characters, not bytes.
+		{"multibyte at the limit", queryOfLength(limit, "é"), true},
+		{"multibyte one over", queryOfLength(limit+1, "é"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			status := http.StatusOK
+			if !tt.ok {
+				status = http.StatusBadRequest
+			}
+			check := func(what string, rec *httptest.ResponseRecorder) {
+				t.Helper()
+				if rec.Code != status {
+					t.Errorf("%s = %d, want %d: %s", what, rec.Code, status, rec.Body)
+				}
+				if !tt.ok && !strings.Contains(rec.Body.String(), "at most 40 characters long") {
+					t.Errorf("%s body = %s, want the length limit", what, rec.Body)
+				}
+			}
+
+			q := url.QueryEscape(tt.query)
+			check("GET /api/query", serve(app, http.MethodGet, "/api/query?sql="+q, "", ""))
+			body, _ := json.Marshal(map[string]string{"sql": tt.query})
+			check("POST /api/query", serve(app, http.MethodPost, "/api/query", "application/json", string(body)))
+			check("GET /api/explain", serve(app, http.MethodGet, "/api/explain?sql="+q, "", ""))
+			check("POST /query", serve(app, http.MethodPost, "/query", "application/x-www-form-urlencoded", "sql="+q))
+
+			// A batch still succeeds, with the error on the long query only.
+			body, _ = json.Marshal([]map[string]string{{"sql": tt.query}, {"sql": "SELECT 1"}})
+			rec := serve(app, http.MethodPost, "/api/batch", "application/json", string(body))
+			var results []BatchResult
+			if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &results) != nil || len(results) != 2 {
+				t.Fatalf("POST /api/batch = %d: %s", rec.Code, rec.Body)
+			}
+			if wantErr := map[bool]string{true: "", false: want}[tt.ok]; results[0].Error != wantErr || results[1].Error != "" {
+				t.Errorf("batch errors = %q, %q, want %q, \"\"", results[0].Error, results[1].Error, wantErr)
+			}
+
+			var out bytes.Buffer
+			err := app.ExecQuery(context.Background(), &out, tt.query, "csv")
+			if (err == nil) != tt.ok {
+				t.Errorf("ExecQuery() error = %v, want ok: %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMaxQueryLengthUnset(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE t (a);", Config{})
+	rec := serve(app, http.MethodGet, "/api/query?sql="+url.QueryEscape(queryOfLength(100000, "x")), "", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("a long query with no limit = %d: %.200s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,72 @@
+// querylog.go
+package explorer
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLoggedSQL is how many bytes of a query's SQL the per-query log line
+// shows; slow query warnings always show all of it.
+const maxLoggedSQL = 200
+
+// logQuery logs a query the explorer ran and how long it took, and warns
+// about it, with its full SQL and arguments, if it took longer than
+// slowQueryThreshold.
+func (a *App) logQuery(query string, args []interface{}, elapsed time.Duration) {
+	log.Printf("level=info msg=%q duration=%s sql=%q", "query", elapsed, truncateSQL(query))
+	if a.slowQueryThreshold > 0 && elapsed > a.slowQueryThreshold {
+		log.Printf("level=warn msg=%q duration=%s threshold=%s sql=%q params=%q", "slow query", elapsed, a.slowQueryThreshold, query, a.formatQueryArgs(args))
+	}
+}
+
+// truncateSQL collapses query's whitespace to single spaces and cuts it to
+// maxLoggedSQL bytes, without splitting a character.
+func truncateSQL(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) <= maxLoggedSQL {
+		return query
+	}
+	cut := maxLoggedSQL
+	for cut > 0 && !utf8.RuneStart(query[cut]) {
+		cut--
+	}
+	return query[:cut] + "..."
+}
+
+// formatQueryArgs returns args as a bracketed list, e.g. `[42 :name="x"]`,
+// with every value replaced by "?" when -redact-query-params is set.
+func (a *App) formatQueryArgs(args []interface{}) string {
+	items := make([]string, len(args))
+	for i, arg := range args {
+		name := ""
+		if named, ok := arg.(sql.NamedArg); ok {
+			name, arg = ":"+named.Name+"=", named.Value
+		}
+		value := "?"
+		if !a.redactQueryParams {
+			value = formatQueryArg(arg)
+		}
+		items[i] = name + value
+	}
+	return "[" + strings.Join(items, " ") + "]"
+}
+
+// formatQueryArg returns a query argument as it appears in the log: strings
+// quoted, blobs as their length and nil as NULL.
+func formatQueryArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
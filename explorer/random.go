@@ -0,0 +1,85 @@
+// random.go
+package explorer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRandomRows = 5
+	maxRandomRows     = 100
+)
+
+// randomRows returns up to n randomly chosen rows of a table.
+//
+// The default "rowid" method picks random rowids between the table's minimum
+// and maximum and fetches the first row at or after each one. Each pick is an
+// index lookup, so it stays fast on huge tables, but rows that follow large
+// rowid gaps are more likely to be chosen and fewer than n rows may come back
+// for very sparse tables. The "order" method uses ORDER BY RANDOM(), which is
+// unbiased but scans and sorts the whole table. Tables without a rowid always
+// use "order". The method actually used is returned.
+func (a *App) randomRows(ctx context.Context, tableName string, n int, method string) ([]Column, [][]interface{}, string, error) {
+	if method != "order" {
+		columns, rows, err := a.randomRowsByRowid(ctx, tableName, n)
+		if err == nil || !strings.Contains(err.Error(), "no such column: rowid") {
+			return columns, rows, "rowid", err
+		}
+		// WITHOUT ROWID table: fall back to a full shuffle.
+	}
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY RANDOM() LIMIT ?", quoteIdent(tableName))
+	columns, rows, err := a.executeCustomQuery(ctx, query, n)
+	return columns, rows, "order", err
+}
+
+// randomRowsByRowid implements the "rowid" sampling method of randomRows.
+func (a *App) randomRowsByRowid(ctx context.Context, tableName string, n int) ([]Column, [][]interface{}, error) {
+	var minID, maxID sql.NullInt64
+	rangeQuery := fmt.Sprintf("SELECT MIN(rowid), MAX(rowid) FROM %s", quoteIdent(tableName))
+	if err := a.conn().QueryRowContext(ctx, rangeQuery).Scan(&minID, &maxID); err != nil {
+		return nil, nil, err
+	}
+
+	// Select the rowid alongside the row so duplicate picks can be detected;
+	// it is stripped from the results below.
+	query := fmt.Sprintf("SELECT rowid, * FROM %s WHERE rowid >= ? ORDER BY rowid LIMIT 1", quoteIdent(tableName))
+	if !minID.Valid {
+		// Empty table: run the query anyway so the column list is returned.
+		columns, _, err := a.executeCustomQuery(ctx, query, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		return columns[1:], nil, nil
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	span := maxID.Int64 - minID.Int64 + 1
+	if span < int64(n) {
+		n = int(span)
+	}
+
+	var (
+		columns []Column
+		sample  [][]interface{}
+		seen    = make(map[interface{}]bool)
+	)
+	// Allow some extra attempts for picks that land on an already chosen row.
+	for attempts := 0; len(sample) < n && attempts < 3*n; attempts++ {
+		cols, rows, err := a.executeCustomQuery(ctx, query, minID.Int64+rng.Int63n(span))
+		if err != nil {
+			return nil, nil, err
+		}
+		columns = cols[1:]
+		if len(rows) == 0 || seen[rows[0][0]] {
+			continue
+		}
+		seen[rows[0][0]] = true
+		sample = append(sample, rows[0][1:])
+	}
+	return columns, sample, nil
+}
@@ -0,0 +1,217 @@
+// relationships.go
+package explorer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// RelationshipGraph describes the tables of the main database and the foreign
+// keys between them, for drawing entity-relationship diagrams.
+type RelationshipGraph struct {
+	Nodes []RelationshipNode `json:"nodes"`
+	Edges []RelationshipEdge `json:"edges"`
+}
+
+// RelationshipNode is a table with its columns.
+type RelationshipNode struct {
+	Table   string       `json:"table"`
+	Columns []ColumnInfo `json:"columns"`
+}
+
+// RelationshipEdge is a foreign key from column FromCol of table From to
+// column ToCol of table To. A foreign key over several columns becomes one
+// edge per column, sharing ID.
+type RelationshipEdge struct {
+	ID      int    `json:"id"` // Index of the foreign key in PRAGMA foreign_key_list(From)
+	From    string `json:"from"`
+	FromCol string `json:"fromCol"`
+	To      string `json:"to"`
+	ToCol   string `json:"toCol"`
+}
+
+// relationshipCache holds the last graph built, for the schema version it
+// was built from. The zero value is an empty cache.
+type relationshipCache struct {
+	mu      sync.Mutex
+	version int64
+	graph   *RelationshipGraph
+}
+
+// get returns the cached graph if it was built for schema version.
+func (c *relationshipCache) get(version int64) (*RelationshipGraph, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.graph, c.graph != nil && c.version == version
+}
+
+// put caches graph as built for schema version.
+func (c *relationshipCache) put(version int64, graph *RelationshipGraph) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version, c.graph = version, graph
+}
+
+// reset forgets the cached graph, e.g. after the database was replaced.
+func (c *relationshipCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.graph = nil
+}
+
+// handleAPIRelationships returns the RelationshipGraph of the database. The
+// graph is cached until the schema changes, which SQLite's schema_version
+// tracks.
+func (a *App) handleAPIRelationships(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	ctx := r.Context()
+	var version int64
+	if err := a.conn().QueryRowContext(ctx, "PRAGMA schema_version").Scan(&version); err != nil {
+		a.respondWithInternalError(w, r, "Failed to read schema version", err)
+		return
+	}
+	graph, ok := a.relations.get(version)
+	if !ok {
+		var err error
+		if graph, err = a.relationshipGraph(ctx); err != nil {
+			a.respondWithInternalError(w, r, "Failed to read foreign keys", err)
+			return
+		}
+		a.relations.put(version, graph)
+	}
+	a.respondWithJSON(w, http.StatusOK, graph)
+}
+
+// relationshipGraph builds the RelationshipGraph from each table's columns
+// and PRAGMA foreign_key_list.
+func (a *App) relationshipGraph(ctx context.Context) (*RelationshipGraph, error) {
+	names, err := a.tableNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	graph := &RelationshipGraph{Nodes: []RelationshipNode{}, Edges: []RelationshipEdge{}}
+	for _, name := range names {
+		columns, err := a.tableInfo(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		graph.Nodes = append(graph.Nodes, RelationshipNode{Table: name, Columns: columns})
+
+		edges, err := a.foreignKeys(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		graph.Edges = append(graph.Edges, edges...)
+	}
+	return graph, nil
+}
+
+// tableNames returns the names of the main database's tables, in name order,
+// leaving out shadow tables unless -show-shadow-tables is set.
+func (a *App) tableNames(ctx context.Context) ([]string, error) {
+	hidden := map[string]bool{}
+	if !a.showShadowTables {
+		shadows, err := a.shadowTables(ctx, "main")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range shadows {
+			hidden[name] = true
+		}
+	}
+
+	rows, err := a.conn().QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !hidden[name] {
+			names = append(names, name)
+		}
+	}
+	return names, rows.Err()
+}
+
+// foreignKeys returns the edges for the foreign keys of tableName. Foreign
+// keys that omit the parent columns refer to the parent's primary key, so
+// those columns are filled in from it.
+func (a *App) foreignKeys(ctx context.Context, tableName string) ([]RelationshipEdge, error) {
+	rows, err := a.conn().QueryContext(ctx, fmt.Sprintf("PRAGMA foreign_key_list(%s)", quoteIdent(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type fkColumn struct {
+		edge RelationshipEdge
+		seq  int
+		to   sql.NullString
+	}
+	var fks []fkColumn
+	for rows.Next() {
+		var (
+			fk                          fkColumn
+			onUpdate, onDelete, matchBy string
+		)
+		fk.edge.From = tableName
+		if err := rows.Scan(&fk.edge.ID, &fk.seq, &fk.edge.To, &fk.edge.FromCol, &fk.to, &onUpdate, &onDelete, &matchBy); err != nil {
+			return nil, err
+		}
+		fks = append(fks, fk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	edges := make([]RelationshipEdge, len(fks))
+	for i, fk := range fks {
+		fk.edge.ToCol = fk.to.String
+		if !fk.to.Valid {
+			pk, err := a.primaryKeyColumns(ctx, fk.edge.To)
+			if err != nil {
+				return nil, err
+			}
+			if fk.seq < len(pk) {
+				fk.edge.ToCol = pk[fk.seq]
+			}
+		}
+		edges[i] = fk.edge
+	}
+	return edges, nil
+}
+
+// primaryKeyColumns returns the columns of tableName's primary key in key
+// order, or nil if it has none (or doesn't exist).
+func (a *App) primaryKeyColumns(ctx context.Context, tableName string) ([]string, error) {
+	columns, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	var pk []string
+	for n := 1; ; n++ {
+		found := false
+		for _, col := range columns {
+			if col.PK == n {
+				pk = append(pk, col.Name)
+				found = true
+			}
+		}
+		if !found {
+			return pk, nil
+		}
+	}
+}
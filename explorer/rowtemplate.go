@@ -0,0 +1,106 @@
+// rowtemplate.go
+package explorer
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TemplateColumn describes one column of an insert template: the placeholder
+// it gets in the template and what a client needs to know to fill it in.
+type TemplateColumn struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`           // Declared type
+	Value         interface{} `json:"value"`          // Placeholder in the template
+	PK            int         `json:"pk"`             // 1-based position within the primary key, 0 if not part of it
+	AutoIncrement bool        `json:"auto_increment"` // An alias of the rowid, assigned by SQLite when null
+	NotNull       bool        `json:"notnull"`
+	Default       *string     `json:"dflt_value"` // Default value expression, nil if none
+}
+
+// handleAPIRowTemplate returns a skeleton row of a table for
+// /api/table/{name}/import: an object with every column that can be written
+// and a placeholder value for its type, along with the details of each
+// column. It only reads the schema, so it works without -writable too.
+func (a *App) handleAPIRowTemplate(w http.ResponseWriter, r *http.Request, tableName string) {
+	columns, err := a.rowTemplate(r.Context(), tableName)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to get table schema", err)
+		return
+	}
+	template := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		template[col.Name] = col.Value
+	}
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"tableName": tableName,
+		"template":  template,
+		"columns":   columns,
+	})
+}
+
+// rowTemplate returns the columns of tableName an insert can set, in table
+// order. Generated columns and the hidden columns of virtual tables are left
+// out, since they can't be written.
+func (a *App) rowTemplate(ctx context.Context, tableName string) ([]TemplateColumn, error) {
+	info, err := a.tableInfo(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	alias, err := a.rowidAlias(ctx, tableName, info)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]TemplateColumn, 0, len(info))
+	for _, col := range info {
+		if col.Hidden != columnOrdinary {
+			continue
+		}
+		tc := TemplateColumn{
+			Name:          col.Name,
+			Type:          col.Type,
+			Value:         placeholderValue(col.Type),
+			PK:            col.PK,
+			AutoIncrement: col.Name == alias,
+			NotNull:       col.NotNull,
+			Default:       col.Default,
+		}
+		if tc.AutoIncrement {
+			tc.Value = nil
+		}
+		columns = append(columns, tc)
+	}
+	return columns, nil
+}
+
+// rowidAlias returns the column of tableName that is an alias of its rowid,
+// or "" if it has none. Only a single-column primary key declared exactly
+// INTEGER is an alias, and only in tables that have a rowid.
+func (a *App) rowidAlias(ctx context.Context, tableName string, info []ColumnInfo) (string, error) {
+	var pk []ColumnInfo
+	for _, col := range info {
+		if col.PK > 0 {
+			pk = append(pk, col)
+		}
+	}
+	if len(pk) != 1 || !strings.EqualFold(pk[0].Type, "INTEGER") {
+		return "", nil
+	}
+	hasRowid, err := a.hasRowid(ctx, tableName)
+	if err != nil || !hasRowid {
+		return "", err
+	}
+	return pk[0].Name, nil
+}
+
+// placeholderValue returns the zero value of a column's declared type as it
+// is sent in JSON: 0 for numeric affinities, and otherwise an empty string.
+// Dates and times have numeric affinity too, but are usually stored as text.
+func placeholderValue(declType string) interface{} {
+	t := strings.ToUpper(declType)
+	if isNumericType(declType) && !strings.Contains(t, "DATE") && !strings.Contains(t, "TIME") {
+		return 0
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+// running.go
+package explorer
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runningQuery is a query in progress, as listed by /api/admin/queries.
+type runningQuery struct {
+	ID       int64     `json:"id"`
+	SQL      string    `json:"sql"` // Shortened like in the query log
+	Started  time.Time `json:"started"`
+	Elapsed  string    `json:"elapsed"` // Filled in when listed
+	ClientIP string    `json:"clientIP"`
+
+	cancel context.CancelFunc
+}
+
+// queryRegistry keeps track of the queries in progress so admins can see and
+// cancel them. The zero value is ready to use.
+type queryRegistry struct {
+	mu      sync.Mutex
+	nextID  int64
+	running map[int64]*runningQuery
+}
+
+// trackQuery registers query, run for r, as in progress. The query must run
+// with the returned context, which cancelQuery cancels, and done must be
+// called once it has finished, which also releases the context.
+func (a *App) trackQuery(r *http.Request, query string) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(r.Context())
+	q := &runningQuery{
+		SQL:      truncateSQL(query),
+		Started:  time.Now(),
+		ClientIP: a.clientIP(r),
+		cancel:   cancel,
+	}
+
+	reg := &a.queries
+	reg.mu.Lock()
+	reg.nextID++
+	q.ID = reg.nextID
+	if reg.running == nil {
+		reg.running = make(map[int64]*runningQuery)
+	}
+	reg.running[q.ID] = q
+	reg.mu.Unlock()
+
+	return ctx, func() {
+		reg.mu.Lock()
+		delete(reg.running, q.ID)
+		reg.mu.Unlock()
+		cancel()
+	}
+}
+
+// runningQueries returns the queries in progress, oldest first.
+func (a *App) runningQueries() []runningQuery {
+	reg := &a.queries
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	now := time.Now()
+	list := make([]runningQuery, 0, len(reg.running))
+	for _, q := range reg.running {
+		entry := *q
+		entry.Elapsed = now.Sub(q.Started).Round(time.Millisecond).String()
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// cancelQuery cancels the context of the running query id, which interrupts
+// SQLite, and reports whether there was such a query.
+func (a *App) cancelQuery(id int64) bool {
+	reg := &a.queries
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	q, ok := reg.running[id]
+	if ok {
+		q.cancel()
+	}
+	return ok
+}
+
+// handleAPIAdminQueries lists the running queries at /api/admin/queries and
+// cancels one with DELETE /api/admin/queries/{id}.
+func (a *App) handleAPIAdminQueries(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/admin/queries")
+	if rest == "" {
+		if !allowMethods(w, r, http.MethodGet) {
+			a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		a.respondWithJSON(w, http.StatusOK, map[string]interface{}{"queries": a.runningQueries()})
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(rest, "/"), 10, 64)
+	if err != nil || id <= 0 {
+		a.respondWithError(w, http.StatusNotFound, "Query not found")
+		return
+	}
+	if !allowMethods(w, r, http.MethodDelete) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !a.cancelQuery(id) {
+		a.respondWithError(w, http.StatusNotFound, "Query not found")
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, map[string]interface{}{"id": id, "cancelled": true})
+}
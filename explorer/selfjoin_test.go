@@ -0,0 +1,124 @@
+// selfjoin_test.go
+package explorer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUniqueColumnNames(t *testing.T) {
+	tests := []struct {
+		columns string
+		want    string
+	}{
+		{"", ""},
+		{"id,name", "id,name"},
+		{"id,name,id,name", "id,name,id:1,name:1"},
+		{"id,id,id", "id,id:1,id:2"},
+		// A suffixed name that is already a column is skipped.
+		{"id,id:1,id", "id,id:1,id:2"},
+		{"id,id,id:1", "id,id:2,id:1"},
+		// Names differing only in case are distinct keys.
+		{"ID,id", "ID,id"},
+	}
+	for _, tt := range tests {
+		var columns []Column
+		if tt.columns != "" {
+			for _, name := range strings.Split(tt.columns, ",") {
+				columns = append(columns, Column{Name: name})
+			}
+		}
+		if got := strings.Join(uniqueColumnNames(columns), ","); got != tt.want {
+			t.Errorf("uniqueColumnNames(%s) = %s, want %s", tt.columns, got, tt.want)
+		}
+	}
+}
+
+const selfJoinSchema = `
+CREATE TABLE employees (id INTEGER PRIMARY KEY, name TEXT, manager_id INTEGER);
+INSERT INTO employees VALUES (1, 'Ada', NULL), (2, 'Brian', 1), (3, 'Cleo', 1);
+`
+
+// selfJoin pairs each employee with their manager, so every column name
+// appears twice.
+const selfJoin = "SELECT * FROM employees e JOIN employees m ON e.manager_id = m.id ORDER BY e.id"
+
+// TestSelfJoin checks that a self-join keeps both copies of each column: as
+// they are in the arrays shape, and under distinct keys in objects.
+func TestSelfJoin(t *testing.T) {
+	app := newTestApp(t, selfJoinSchema, Config{})
+	const wantKeys = "id,name,manager_id,id:1,name:1,manager_id:1"
+	wantObject := map[string]interface{}{"id": 2.0, "name": "Brian", "manager_id": 1.0, "id:1": 1.0, "name:1": "Ada", "manager_id:1": "NULL"}
+
+	t.Run("arrays", func(t *testing.T) {
+		var resp struct {
+			Columns []string        `json:"columns"`
+			Rows    [][]interface{} `json:"rows"`
+		}
+		getJSON(t, app, "/api/query?sql="+url.QueryEscape(selfJoin), http.StatusOK, &resp)
+		if got := strings.Join(resp.Columns, ","); got != "id,name,manager_id,id,name,manager_id" {
+			t.Errorf("columns = %s, want the names as they are", got)
+		}
+		if fmt.Sprint(resp.Rows) != "[[2 Brian 1 1 Ada NULL] [3 Cleo 1 1 Ada NULL]]" {
+			t.Errorf("rows = %v", resp.Rows)
+		}
+	})
+
+	t.Run("objects", func(t *testing.T) {
+		var resp struct {
+			Columns []string                 `json:"columns"`
+			Rows    []map[string]interface{} `json:"rows"`
+		}
+		getJSON(t, app, "/api/query?_shape=objects&sql="+url.QueryEscape(selfJoin), http.StatusOK, &resp)
+		if got := strings.Join(resp.Columns, ","); got != wantKeys {
+			t.Errorf("columns = %s, want %s", got, wantKeys)
+		}
+		if len(resp.Rows) != 2 || fmt.Sprint(resp.Rows[0]) != fmt.Sprint(wantObject) {
+			t.Errorf("rows = %v, want %v first", resp.Rows, wantObject)
+		}
+	})
+
+	t.Run("sparse objects", func(t *testing.T) {
+		var resp struct {
+			Rows []map[string]interface{} `json:"rows"`
+		}
+		getJSON(t, app, "/api/query?_shape=objects&_sparse=on&sql="+url.QueryEscape(selfJoin), http.StatusOK, &resp)
+		if _, ok := resp.Rows[0]["manager_id:1"]; ok || resp.Rows[0]["manager_id"] != 1.0 {
+			t.Errorf("row = %v, want only the NULL manager_id:1 left out", resp.Rows[0])
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		body, _ := json.Marshal([]map[string]string{{"sql": selfJoin, "shape": "objects"}, {"sql": selfJoin}})
+		rec := serve(app, http.MethodPost, "/api/batch", "application/json", string(body))
+		var results []struct {
+			Columns []string        `json:"columns"`
+			Rows    json.RawMessage `json:"rows"`
+			Error   string          `json:"error"`
+		}
+		if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &results) != nil || len(results) != 2 {
+			t.Fatalf("POST /api/batch = %d: %s", rec.Code, rec.Body)
+		}
+		if got := strings.Join(results[0].Columns, ","); got != wantKeys || results[0].Error != "" {
+			t.Errorf("objects result columns = %s, error %q, want %s", got, results[0].Error, wantKeys)
+		}
+		if got := strings.Join(results[1].Columns, ","); got != "id,name,manager_id,id,name,manager_id" {
+			t.Errorf("arrays result columns = %s, want the names as they are", got)
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		rec := serve(app, http.MethodGet, "/api/query?_format=yaml&sql="+url.QueryEscape(selfJoin), "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET yaml = %d: %s", rec.Code, rec.Body)
+		}
+		want := "- id: 2\n  name: Brian\n  manager_id: 1\n  \"id:1\": 1\n  \"name:1\": Ada\n  \"manager_id:1\": null\n"
+		if !strings.HasPrefix(rec.Body.String(), want) {
+			t.Errorf("YAML =\n%s\nwant it to start\n%s", rec.Body, want)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+// slashes.go
+package explorer
+
+import (
+	"net/http"
+	"strings"
+)
+
+// subtreeRoots are the prefixes Handler serves as subtrees. Requested bare,
+// they keep their trailing slash, because ServeMux redirects "/table" back to
+// "/table/"; the handlers answer them with 400 or 404.
+var subtreeRoots = map[string]bool{
+	"/table/":     true,
+	"/db/":        true,
+	"/static/":    true,
+	"/api/table/": true,
+	"/api/db/":    true,
+}
+
+// withoutTrailingSlash redirects requests whose path ends with a slash to the
+// same path without it, e.g. "/table/users/" to "/table/users", so every page
+// and endpoint has a single canonical URL. The query string is kept. GET and
+// HEAD requests get a 301; other methods get a 308, which makes clients repeat
+// the method and body. Slashes within table names are escaped as %2F, so they
+// are never mistaken for a trailing slash. Paths starting with "//" are left
+// to ServeMux, which cleans them, as "//host" would redirect off the site.
+func withoutTrailingSlash(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+		trimmed := strings.TrimRight(path, "/")
+		if trimmed == path || trimmed == "" || subtreeRoots[path] || strings.HasPrefix(path, "//") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		target := trimmed
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, target, code)
+	})
+}
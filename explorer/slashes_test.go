@@ -0,0 +1,59 @@
+// slashes_test.go
+package explorer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrailingSlashRedirects(t *testing.T) {
+	app := newTestApp(t, `CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE TABLE "a/b" (id INTEGER PRIMARY KEY);`, Config{Writable: true})
+	tests := []struct {
+		method   string
+		target   string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/table/users/", http.StatusMovedPermanently, "/table/users"},
+		{http.MethodHead, "/table/users/", http.StatusMovedPermanently, "/table/users"},
+		{http.MethodGet, "/table/users///", http.StatusMovedPermanently, "/table/users"},
+		{http.MethodGet, "/api/table/users/?page=1&_sort=id", http.StatusMovedPermanently, "/api/table/users?page=1&_sort=id"},
+		{http.MethodGet, "/table/users/row/1/", http.StatusMovedPermanently, "/table/users/row/1"},
+		{http.MethodGet, "/api/tables/", http.StatusMovedPermanently, "/api/tables"},
+		{http.MethodPost, "/api/table/users/import/", http.StatusPermanentRedirect, "/api/table/users/import"},
+		{http.MethodPost, "/query/", http.StatusPermanentRedirect, "/query"},
+
+		// Slashes in table names are escaped, so only the real trailing
+		// one goes.
+		{http.MethodGet, "/table/a%2Fb/", http.StatusMovedPermanently, "/table/a%2Fb"},
+		{http.MethodGet, "/table/a%2Fb", http.StatusOK, ""},
+
+		// The root, and the bare subtree prefixes, which name no table,
+		// keep their slash.
+		{http.MethodGet, "/", http.StatusOK, ""},
+		{http.MethodGet, "/table/", http.StatusBadRequest, ""},
+		{http.MethodGet, "/api/table/", http.StatusNotFound, ""},
+
+		// A path starting with "//" must not redirect to another host.
+		{http.MethodGet, "//evil.example/", http.StatusMovedPermanently, "/evil.example/"},
+		{http.MethodGet, "///evil.example//", http.StatusMovedPermanently, "/evil.example/"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://localhost"+tt.target, nil)
+		rec := httptest.NewRecorder()
+		app.Handler().ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, rec.Code, tt.code)
+			continue
+		}
+		location := rec.Header().Get("Location")
+		if location != tt.location {
+			t.Errorf("%s %s redirected to %q, want %q", tt.method, tt.target, location, tt.location)
+		}
+		if strings.HasPrefix(location, "//") {
+			t.Errorf("%s %s redirected off the site, to %q", tt.method, tt.target, location)
+		}
+	}
+}
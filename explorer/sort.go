@@ -0,0 +1,83 @@
+// sort.go
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SortKey is one column of a ?_sort= ordering.
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// String returns the key as written in ?_sort=, e.g. "-created_at".
+func (k SortKey) String() string {
+	if k.Desc {
+		return "-" + k.Column
+	}
+	return k.Column
+}
+
+// sortParam reads ?_sort= from params, which may be repeated and/or
+// comma-separated, e.g. "_sort=status,-created_at", and checks each column
+// against tableName. Keys keep the order given; a "-" prefix sorts that
+// column descending.
+func (a *App) sortParam(ctx context.Context, params *queryParams, tableName string) ([]SortKey, error) {
+	var keys []SortKey
+	seen := map[string]bool{}
+	for _, item := range splitColumnsParam(params.values["_sort"]) {
+		key := SortKey{Column: strings.TrimPrefix(item, "-")}
+		key.Desc = key.Column != item
+		if key.Column == "" {
+			params.fail("_sort", "_sort has a \"-\" without a column")
+			continue
+		}
+		if seen[key.Column] {
+			params.fail("_sort", fmt.Sprintf("_sort lists column %s more than once", key.Column))
+			continue
+		}
+		seen[key.Column] = true
+		keys = append(keys, key)
+	}
+
+	cols := make([]string, len(keys))
+	for i, key := range keys {
+		cols[i] = key.Column
+	}
+	err := a.checkColumns(ctx, tableName, cols)
+	var unknown *unknownColumnsError
+	if errors.As(err, &unknown) {
+		params.fail("_sort", err.Error())
+		return nil, nil
+	}
+	return keys, err
+}
+
+// orderBy returns the ORDER BY clause for view: its sort keys if it has any,
+// and otherwise tableName's default sort from the metadata, or "" for neither.
+func (a *App) orderBy(tableName string, view tableView) string {
+	if len(view.Sort) == 0 {
+		return a.defaultOrder(tableName)
+	}
+	terms := make([]string, len(view.Sort))
+	for i, key := range view.Sort {
+		terms[i] = quoteIdent(key.Column)
+		if key.Desc {
+			terms[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// sortString returns keys as a ?_sort= value.
+func sortString(keys []SortKey) string {
+	items := make([]string, len(keys))
+	for i, key := range keys {
+		items[i] = key.String()
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,48 @@
+// sqlerror.go
+package explorer
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// nearTokenRe matches the token SQLite names in syntax errors, as in
+// `near "FORM": syntax error` or `unrecognized token: "'abc"`.
+var nearTokenRe = regexp.MustCompile(`^(?:near "(.*)": syntax error|unrecognized token: "(.*)")$`)
+
+// SQLErrorDetail locates a failed custom query's error within its text.
+type SQLErrorDetail struct {
+	Message   string `json:"message"`
+	NearToken string `json:"nearToken"`
+	Offset    *int   `json:"offset"` // Byte offset of NearToken in the query, nil if it can't be told
+}
+
+// sqlErrorDetail returns the location of err in query, or nil if SQLite
+// didn't name a token to locate it by. SQLite doesn't report the offset
+// itself through the driver, so it is found by searching the query for the
+// token, and left unset when the token occurs more than once.
+func sqlErrorDetail(query string, err error) *SQLErrorDetail {
+	m := nearTokenRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return nil
+	}
+	token := m[1] + m[2]
+	detail := &SQLErrorDetail{Message: err.Error(), NearToken: token}
+	if i := strings.Index(query, token); token != "" && i >= 0 && strings.LastIndex(query, token) == i {
+		detail.Offset = &i
+	}
+	return detail
+}
+
+// markError returns query as HTML with the located error token marked, for
+// the query page.
+func markError(query string, detail *SQLErrorDetail) template.HTML {
+	if detail == nil || detail.Offset == nil {
+		return ""
+	}
+	i, j := *detail.Offset, *detail.Offset+len(detail.NearToken)
+	return template.HTML(template.HTMLEscapeString(query[:i]) +
+		"<mark>" + template.HTMLEscapeString(query[i:j]) + "</mark>" +
+		template.HTMLEscapeString(query[j:]))
+}
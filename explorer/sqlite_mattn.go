@@ -0,0 +1,14 @@
+// sqlite_mattn.go
+
+//go:build !modernc
+
+package explorer
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DefaultDriver is the SQLite driver used when Config.Driver is empty. The
+// default build uses go-sqlite3, which needs cgo; build with -tags modernc
+// for modernc.org/sqlite instead.
+const DefaultDriver = DriverMattn
@@ -0,0 +1,14 @@
+// sqlite_modernc.go
+
+//go:build modernc
+
+package explorer
+
+import (
+	_ "modernc.org/sqlite"
+)
+
+// DefaultDriver is the SQLite driver used when Config.Driver is empty. Built
+// with -tags modernc, godatasette uses the pure Go modernc.org/sqlite and can
+// be compiled without cgo.
+const DefaultDriver = DriverModernc
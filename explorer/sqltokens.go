@@ -0,0 +1,97 @@
+// sqltokens.go
+package explorer
+
+import (
+	"strings"
+)
+
+// QueryParam is a named parameter of a custom query, with the value entered
+// for it in the query form.
+type QueryParam struct {
+	Name  string
+	Value string
+}
+
+// placeholderNames returns the names of the named parameters in query, e.g.
+// "min" for :min, @min or $min, in order of first appearance and without
+// repeats. Like calledFunctions it is a heuristic scan that skips string
+// literals, quoted names and comments rather than a parser.
+func placeholderNames(query string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for i := 0; i < len(query); {
+		if next, ok := skipComment(query, i); ok {
+			i = next
+			continue
+		}
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			_, i = readQuoted(query, i, c)
+		case c == '[':
+			_, i = readQuoted(query, i, ']')
+		case (c == ':' || c == '@' || c == '$') && i+1 < len(query) && isNameByte(query[i+1]):
+			i++
+			start := i
+			for i < len(query) && isNameByte(query[i]) {
+				i++
+			}
+			if name := query[start:i]; !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		case isNameByte(c):
+			// Skip whole names, so a "$" inside one isn't a placeholder.
+			for i < len(query) && isNameByte(query[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	return names
+}
+
+// skipComment returns the index just past the SQL comment starting at
+// query[i], and false if no comment starts there.
+func skipComment(query string, i int) (int, bool) {
+	switch {
+	case strings.HasPrefix(query[i:], "--"):
+		if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+			return i + end + 1, true
+		}
+		return len(query), true
+	case strings.HasPrefix(query[i:], "/*"):
+		if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+			return i + end + 4, true
+		}
+		return len(query), true
+	}
+	return i, false
+}
+
+// readQuoted reads the quoted token starting at query[start] and ending with
+// closing, where a doubled closing character stands for itself. It returns the
+// unquoted contents and the index just past the token.
+func readQuoted(query string, start int, closing byte) (string, int) {
+	var b strings.Builder
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != closing {
+			b.WriteByte(query[i])
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == closing && closing != ']' {
+			b.WriteByte(closing)
+			i++
+			continue
+		}
+		return b.String(), i + 1
+	}
+	return b.String(), len(query)
+}
+
+// isNameByte reports whether c can be part of an unquoted SQLite identifier.
+func isNameByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
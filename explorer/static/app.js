@@ -0,0 +1,99 @@
+// Client-side behaviour shared by GoDB-Explorer pages.
+(function () {
+    "use strict";
+
+    // Client-side sorting: click a column header to toggle ascending/descending order.
+    document.querySelectorAll("th[data-sortable]").forEach(function (th) {
+        th.addEventListener("click", function () {
+            var tbody = th.closest("table").querySelector("tbody");
+            var index = Array.prototype.indexOf.call(th.parentNode.children, th);
+            var asc = th.dataset.order !== "asc";
+            th.parentNode.querySelectorAll("th").forEach(function (other) { delete other.dataset.order; });
+            th.dataset.order = asc ? "asc" : "desc";
+
+            var rows = Array.prototype.slice.call(tbody.rows).filter(function (row) { return row.cells.length > index; });
+            rows.sort(function (a, b) {
+                var x = a.cells[index].textContent.trim(), y = b.cells[index].textContent.trim();
+                var nx = parseFloat(x), ny = parseFloat(y);
+                var cmp = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+                return asc ? cmp : -cmp;
+            });
+            rows.forEach(function (row) { tbody.appendChild(row); });
+        });
+    });
+
+    // Column summary popover, backed by /api/table/{name}/stats.
+    var popover = document.getElementById("stats-popover");
+    if (popover) {
+        var table = popover.dataset.table;
+        document.querySelectorAll("button[data-stats-column]").forEach(function (button) {
+            button.addEventListener("click", function (event) {
+                event.stopPropagation();
+                var column = button.dataset.statsColumn;
+                var rect = button.getBoundingClientRect();
+                popover.style.top = (window.scrollY + rect.bottom + 4) + "px";
+                popover.style.left = (window.scrollX + rect.left) + "px";
+                popover.textContent = "Loading…";
+                popover.classList.remove("hidden");
+                fetch("/api/table/" + encodeURIComponent(table) + "/stats?column=" + encodeURIComponent(column))
+                    .then(function (resp) { return resp.json(); })
+                    .then(function (body) {
+                        popover.textContent = "";
+                        if (body.error) {
+                            popover.textContent = body.error;
+                            return;
+                        }
+                        var title = document.createElement("p");
+                        title.className = "mb-2 font-semibold text-gray-900";
+                        title.textContent = column + (body.type ? " (" + body.type + ")" : "");
+                        popover.appendChild(title);
+                        Object.keys(body.stats).forEach(function (key) {
+                            var line = document.createElement("p");
+                            line.className = "flex justify-between text-gray-700";
+                            line.innerHTML = "<span></span><span class=\"font-mono\"></span>";
+                            line.children[0].textContent = key;
+                            line.children[1].textContent = body.stats[key] === null ? "NULL" : body.stats[key];
+                            popover.appendChild(line);
+                        });
+                    });
+            });
+        });
+        document.addEventListener("click", function (event) {
+            if (!popover.contains(event.target)) {
+                popover.classList.add("hidden");
+            }
+        });
+    }
+
+    // Copy permalink: copy the link's absolute URL instead of following it,
+    // falling back to navigation where the clipboard isn't available.
+    document.querySelectorAll("a[data-copy-link]").forEach(function (link) {
+        link.addEventListener("click", function (event) {
+            if (!navigator.clipboard) {
+                return;
+            }
+            event.preventDefault();
+            navigator.clipboard.writeText(link.href).then(function () {
+                var label = link.textContent;
+                link.textContent = "Copied";
+                setTimeout(function () { link.textContent = label; }, 1500);
+            });
+        });
+    });
+
+    // Copy buttons: copy their data-copy-text, e.g. the curl command of the
+    // API expander.
+    document.querySelectorAll("button[data-copy-text]").forEach(function (button) {
+        if (!navigator.clipboard) {
+            button.hidden = true;
+            return;
+        }
+        button.addEventListener("click", function () {
+            navigator.clipboard.writeText(button.dataset.copyText).then(function () {
+                var label = button.textContent;
+                button.textContent = "Copied";
+                setTimeout(function () { button.textContent = label; }, 1500);
+            });
+        });
+    });
+})();
@@ -0,0 +1,17 @@
+// Loaded synchronously in <head> so the theme is applied before first paint.
+// The server sets the "dark" class when the theme cookie asks for it; with the
+// default "system" theme we follow prefers-color-scheme instead.
+tailwind.config = { darkMode: "class" };
+
+(function () {
+    "use strict";
+
+    var root = document.documentElement;
+    if (root.dataset.theme !== "system") {
+        return;
+    }
+    var query = window.matchMedia("(prefers-color-scheme: dark)");
+    var apply = function () { root.classList.toggle("dark", query.matches); };
+    apply();
+    query.addEventListener("change", apply);
+})();
@@ -0,0 +1,92 @@
+// stream.go
+package explorer
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// TableRow is one row of the table view.
+type TableRow struct {
+	Values []interface{}
+	Link   string // Detail page URL, empty when rows aren't linkable
+}
+
+// streamTable runs query, which reads from tableName, and returns its columns
+// and a channel yielding the rows as they are read, so a page is rendered
+// without holding all of its rows in memory. linked reports whether the rows
+// carry detail page links. The channel is closed after the last row, after a
+// read error (which is logged, as the page is already being written by then),
+// or once ctx is done, so callers that may stop reading early must cancel ctx.
+// The query is logged once the channel is closed, with a duration that
+// includes the time the caller took to consume the rows.
+func (a *App) streamTable(ctx context.Context, tableName, query string, args ...interface{}) (columns []Column, stream <-chan TableRow, linked bool, err error) {
+	start := time.Now()
+	ctx, sp := a.traceQuery(ctx, query)
+	sp.setString("db.sql.table", tableName)
+	rows, err := a.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		a.logQuery(query, args, time.Since(start))
+		sp.fail(err)
+		sp.finish()
+		return nil, nil, false, err
+	}
+	columns, err = rowColumns(rows)
+	if err != nil {
+		rows.Close()
+		a.logQuery(query, args, time.Since(start))
+		sp.fail(err)
+		sp.finish()
+		return nil, nil, false, err
+	}
+	link := a.rowLinker(ctx, tableName, columns)
+
+	ch := make(chan TableRow)
+	go func() {
+		defer close(ch)
+		defer func() {
+			a.logQuery(query, args, time.Since(start))
+			sp.finish()
+		}()
+		defer rows.Close()
+		for rows.Next() {
+			values, err := a.scanRow(rows, columns)
+			if err != nil {
+				log.Printf("Error reading rows of table %s: %v", tableName, err)
+				sp.fail(err)
+				return
+			}
+			row := TableRow{Values: values}
+			if link != nil {
+				row.Link = link(values)
+			}
+			select {
+			case ch <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := rows.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Error reading rows of table %s: %v", tableName, err)
+			sp.fail(err)
+		}
+	}()
+	return columns, ch, link != nil, nil
+}
+
+// sliceRows returns a closed channel yielding rows that were already read,
+// with their links if links isn't nil, for views that share the table
+// template.
+func sliceRows(rows [][]interface{}, links []string) <-chan TableRow {
+	ch := make(chan TableRow, len(rows))
+	for i, values := range rows {
+		row := TableRow{Values: values}
+		if links != nil {
+			row.Link = links[i]
+		}
+		ch <- row
+	}
+	close(ch)
+	return ch
+}
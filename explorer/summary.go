@@ -0,0 +1,111 @@
+// summary.go
+package explorer
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// DBSummary gives an overview of the main database, for the index page and
+// /api/summary.
+type DBSummary struct {
+	FileSize  int64 `json:"fileSize"`  // Size of the database file in bytes, -1 if unknown
+	PageSize  int64 `json:"pageSize"`  // PRAGMA page_size
+	PageCount int64 `json:"pageCount"` // PRAGMA page_count
+	Size      int64 `json:"size"`      // PageSize * PageCount, the size SQLite reports
+	Tables    int   `json:"tables"`
+	Views     int   `json:"views"`
+	Rows      int64 `json:"rows"` // Rows across all tables, leaving out any that couldn't be counted
+
+	UserVersion   int64 `json:"userVersion"`   // PRAGMA user_version, 0 if unset
+	ApplicationID int64 `json:"applicationId"` // PRAGMA application_id, 0 if unset
+}
+
+// handleAPISummary returns the DBSummary of the database.
+func (a *App) handleAPISummary(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	summary, err := a.dbSummary(r.Context(), nil)
+	if err != nil {
+		a.respondWithInternalError(w, r, "Failed to summarize database", err)
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, summary)
+}
+
+// dbSummary summarizes the main database. Counting every table's rows can be
+// slow on big databases, so the row counts of counted, tables getTables has
+// already counted, are reused rather than counted again; only the main
+// database's tables missing from it are counted here. Tables and row totals
+// leave out shadow tables unless -show-shadow-tables is set, like the index.
+func (a *App) dbSummary(ctx context.Context, counted []Table) (*DBSummary, error) {
+	summary := &DBSummary{FileSize: -1}
+	if a.dbPath != "" {
+		if info, err := os.Stat(a.dbPath); err == nil {
+			summary.FileSize = info.Size()
+		}
+	}
+	db := a.conn()
+	if err := db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&summary.PageSize); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&summary.PageCount); err != nil {
+		return nil, err
+	}
+	summary.Size = summary.PageSize * summary.PageCount
+	// Read on every request rather than at startup, since migrations change
+	// them while the server runs.
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&summary.UserVersion); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA application_id").Scan(&summary.ApplicationID); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type='view'").Scan(&summary.Views); err != nil {
+		return nil, err
+	}
+
+	known := map[string]int64{}
+	for _, t := range counted {
+		if t.Schema == "main" {
+			known[t.Name] = t.RowCount
+		}
+	}
+	names, err := a.tableNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summary.Tables = len(names)
+	for _, name := range names {
+		count, ok := known[name]
+		if !ok {
+			if count, err = a.countRows(ctx, name, ""); err != nil {
+				log.Printf("Could not count rows for table %s: %v", name, err)
+				continue
+			}
+		}
+		if count > 0 {
+			summary.Rows += count
+		}
+	}
+	return summary, nil
+}
+
+// formatBytes formats a size in bytes for display, e.g. "1.5 MB".
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	size, unit := float64(n)/1024, 0
+	units := []string{"KB", "MB", "GB", "TB"}
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	return strconv.FormatFloat(size, 'f', 1, 64) + " " + units[unit]
+}
@@ -0,0 +1,258 @@
+// table_test.go
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// getJSON serves GET target on app, failing t unless it returns want, and
+// decodes the response into v.
+func getJSON(t *testing.T, app *App, target string, want int, v interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != want {
+		t.Fatalf("GET %s = %d, want %d: %s", target, rec.Code, want, rec.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+	}
+}
+
+// adversarialTables are table names that break out of naive quoting.
+var adversarialTables = []string{
+	`quote"inside`,
+	`semi;colon`,
+	`with space`,
+	`x"; DROP TABLE users; --`,
+	`'single'`,
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := map[string]string{
+		"users":        `"users"`,
+		`quote"inside`: `"quote""inside"`,
+		`""`:           `""""""`,
+		"with space":   `"with space"`,
+	}
+	for name, want := range tests {
+		if got := quoteIdent(name); got != want {
+			t.Errorf("quoteIdent(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestAdversarialTableNames(t *testing.T) {
+	schema := "CREATE TABLE users (id INTEGER PRIMARY KEY); INSERT INTO users VALUES (1);"
+	for _, name := range adversarialTables {
+		schema += "CREATE TABLE " + quoteIdent(name) + " (v TEXT); INSERT INTO " + quoteIdent(name) + " VALUES (" + quoteIdent(name) + ");"
+	}
+	// Writable, so that an injected statement could do damage.
+	app := newTestApp(t, schema, Config{Writable: true})
+
+	for _, name := range adversarialTables {
+		path := url.PathEscape(name)
+		var resp struct {
+			TableName string          `json:"tableName"`
+			Rows      [][]interface{} `json:"rows"`
+		}
+		getJSON(t, app, "/api/table/"+path, http.StatusOK, &resp)
+		if resp.TableName != name || len(resp.Rows) != 1 {
+			t.Errorf("/api/table/%s = %q with %d rows, want %q with 1", path, resp.TableName, len(resp.Rows), name)
+		}
+		getJSON(t, app, "/table/"+path, http.StatusOK, nil)
+		getJSON(t, app, "/api/table/"+path+"/count", http.StatusOK, nil)
+	}
+
+	// Names that aren't tables are rejected before any query is built,
+	// whatever they hold.
+	for _, name := range []string{
+		`users"; DROP TABLE users; --`,
+		`users" --`,
+		"users;",
+		"sqlite_master",
+		"users WHERE 1=0",
+	} {
+		getJSON(t, app, "/api/table/"+url.PathEscape(name), http.StatusNotFound, nil)
+		getJSON(t, app, "/table/"+url.PathEscape(name), http.StatusNotFound, nil)
+	}
+	var resp struct {
+		TotalRows int `json:"totalRows"`
+	}
+	getJSON(t, app, "/api/table/users", http.StatusOK, &resp)
+	if resp.TotalRows != 1 {
+		t.Errorf("users has %d rows after the adversarial requests, want 1", resp.TotalRows)
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	tests := []struct {
+		rows      int64
+		wantPages int
+	}{
+		{0, 0},
+		{1, 1},
+		{rowsPerPage, 1},
+		{rowsPerPage + 1, 2},
+		{2 * rowsPerPage, 2},
+	}
+	for _, tt := range tests {
+		pages := pageCount(tt.rows)
+		if pages != tt.wantPages {
+			t.Errorf("pageCount(%d) = %d, want %d", tt.rows, pages, tt.wantPages)
+		}
+		// Every page count has a valid first page, even with no rows, and
+		// nothing past the last.
+		if got := clampPage(1, pages); got != 1 {
+			t.Errorf("clampPage(1, %d) = %d, want 1", pages, got)
+		}
+		if got := clampPage(0, pages); got != 1 {
+			t.Errorf("clampPage(0, %d) = %d, want 1", pages, got)
+		}
+		want := pages
+		if want == 0 {
+			want = 1
+		}
+		if got := clampPage(9999, pages); got != want {
+			t.Errorf("clampPage(9999, %d) = %d, want %d", pages, got, want)
+		}
+	}
+}
+
+// The Next and Previous links of the table page.
+var (
+	nextLinkRe = regexp.MustCompile(`<a href="\?page=\d+"[^>]*>\s*Next`)
+	prevLinkRe = regexp.MustCompile(`<a href="\?page=\d+"[^>]*>\s*<svg[^>]*>\s*<path[^>]*/>\s*</svg>\s*Previous`)
+)
+
+// TestTablePageBounds checks the pages of tables with no rows, exactly one
+// full page, and one row more than a page.
+func TestTablePageBounds(t *testing.T) {
+	tests := []struct {
+		rows      int
+		wantPages int
+		lastRows  int // Rows on the last page
+	}{
+		{0, 0, 0},
+		{rowsPerPage, 1, rowsPerPage},
+		{rowsPerPage + 1, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.rows)+" rows", func(t *testing.T) {
+			app := newTestApp(t, fmt.Sprintf(`CREATE TABLE t (i INTEGER);
+				WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < %d)
+				INSERT INTO t SELECT i FROM n WHERE i <= %[1]d;`, tt.rows), Config{})
+			last := tt.wantPages
+			if last == 0 {
+				last = 1
+			}
+
+			var resp struct {
+				Page       int             `json:"page"`
+				TotalRows  int             `json:"totalRows"`
+				TotalPages int             `json:"totalPages"`
+				Rows       [][]interface{} `json:"rows"`
+			}
+			getJSON(t, app, "/api/table/t?page="+strconv.Itoa(last), http.StatusOK, &resp)
+			if resp.TotalRows != tt.rows || resp.TotalPages != tt.wantPages || len(resp.Rows) != tt.lastRows {
+				t.Errorf("last page: %d rows of %d in %d pages, want %d of %d in %d",
+					len(resp.Rows), resp.TotalRows, resp.TotalPages, tt.lastRows, tt.rows, tt.wantPages)
+			}
+			// The API clamps pages past the end to the last one.
+			getJSON(t, app, "/api/table/t?page=9999", http.StatusOK, &resp)
+			if resp.Page != last || len(resp.Rows) != tt.lastRows {
+				t.Errorf("?page=9999 gave page %d with %d rows, want page %d with %d", resp.Page, len(resp.Rows), last, tt.lastRows)
+			}
+
+			// The table page redirects pages past the end to the last one,
+			// and serves the valid ones. Pages below 1 aren't pages at all.
+			getJSON(t, app, "/table/t?page=0", http.StatusBadRequest, nil)
+			for _, page := range []string{"9999", strconv.Itoa(last + 1)} {
+				want := strconv.Itoa(last)
+				rec := httptest.NewRecorder()
+				app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/table/t?page="+page, nil))
+				if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/table/t?page="+want {
+					t.Errorf("/table/t?page=%s = %d to %q, want a redirect to page %s", page, rec.Code, rec.Header().Get("Location"), want)
+				}
+			}
+			for page := 1; page <= last; page++ {
+				rec := httptest.NewRecorder()
+				app.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/table/t?page="+strconv.Itoa(page), nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("/table/t?page=%d = %d", page, rec.Code)
+				}
+				body := rec.Body.String()
+				if hasNext := nextLinkRe.MatchString(body); hasNext != (page < tt.wantPages) {
+					t.Errorf("page %d of %d has a Next link: %v", page, tt.wantPages, hasNext)
+				}
+				if hasPrev := prevLinkRe.MatchString(body); hasPrev != (page > 1) {
+					t.Errorf("page %d of %d has a Previous link: %v", page, tt.wantPages, hasPrev)
+				}
+			}
+		})
+	}
+}
+
+// TestTablePageQueryIsStable checks that every page of a view is read with
+// the same SQL, the page size and offset being bound rather than written into
+// it, and that the bound values select the right rows.
+func TestTablePageQueryIsStable(t *testing.T) {
+	app := newTestApp(t, `CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 175)
+		INSERT INTO t SELECT i, 'row ' || i FROM n;`, Config{})
+	ctx := context.Background()
+	views := map[string]tableView{
+		"plain":  {},
+		"search": {Search: "row"},
+		"sorted": {Sort: []SortKey{{Column: "name", Desc: true}}},
+	}
+	for name, view := range views {
+		t.Run(name, func(t *testing.T) {
+			first, _, err := app.tablePageQuery(ctx, "t", 1, view)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(first, " LIMIT ? OFFSET ?") {
+				t.Errorf("query %q does not bind its LIMIT and OFFSET", first)
+			}
+			for page := 1; page <= 4; page++ {
+				query, args, err := app.tablePageQuery(ctx, "t", page, view)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if query != first {
+					t.Errorf("page %d query = %q, want %q as on page 1", page, query, first)
+				}
+				if len(args) < 2 {
+					t.Fatalf("page %d args = %v, want the page size and offset last", page, args)
+				}
+				limit, offset := args[len(args)-2], args[len(args)-1]
+				if limit != rowsPerPage || offset != (page-1)*rowsPerPage {
+					t.Errorf("page %d binds LIMIT %v OFFSET %v, want %d and %d", page, limit, offset, rowsPerPage, (page-1)*rowsPerPage)
+				}
+			}
+		})
+	}
+
+	// The bound values page through the rows as the literal ones did.
+	var resp struct {
+		Rows [][]interface{} `json:"rows"`
+	}
+	for page, want := range map[int][2]float64{1: {1, 50}, 2: {51, 100}, 4: {151, 175}} {
+		getJSON(t, app, "/api/table/t?page="+strconv.Itoa(page), http.StatusOK, &resp)
+		if len(resp.Rows) == 0 || resp.Rows[0][0] != want[0] || resp.Rows[len(resp.Rows)-1][0] != want[1] {
+			t.Errorf("page %d = %v, want ids %v to %v", page, resp.Rows, want[0], want[1])
+		}
+	}
+}
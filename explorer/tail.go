@@ -0,0 +1,153 @@
+// tail.go
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTailInterval is how often /table/{name}/tail polls for new rows when
+// -tail-interval isn't set.
+const defaultTailInterval = 2 * time.Second
+
+// tailBatchSize caps the rows sent per poll. A full batch is followed by
+// another poll straight away rather than after the interval.
+const tailBatchSize = 500
+
+// handleTail streams rows appended to a table as Server-Sent Events, for
+// watching append-only tables like logs. It polls for rows with a rowid above
+// the last one sent every tail interval; each event's data is the row as a
+// JSON object and its id is the rowid, so a reconnecting EventSource resumes
+// where it left off through Last-Event-ID. Without one, the stream starts
+// after ?after=<rowid>, or else with the rows added from now on. Rows that are
+// updated or deleted, or inserted with a lower rowid, aren't noticed.
+func (a *App) handleTail(w http.ResponseWriter, r *http.Request, tableName string) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed", nil)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		a.renderError(w, r, http.StatusInternalServerError, "Streaming is not supported", nil)
+		return
+	}
+
+	params := newQueryParams(r)
+	after, hasAfter := params.int64("after")
+	if err := params.err(); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	ctx := r.Context()
+	after, err := a.tailStart(ctx, r, tableName, after, hasAfter)
+	if errors.Is(err, errNoRowid) {
+		a.renderError(w, r, http.StatusBadRequest, "Only tables with a rowid can be tailed", nil)
+		return
+	}
+	var badStart *strconv.NumError
+	if errors.As(err, &badStart) {
+		a.renderError(w, r, http.StatusBadRequest, "Last-Event-ID must be an integer rowid", nil)
+		return
+	}
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, "Failed to read table", err)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	if r.Method == http.MethodHead {
+		return
+	}
+	// Ask clients to reconnect at the poll interval if the connection drops,
+	// e.g. when the server's write timeout ends it.
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", a.tailInterval.Milliseconds()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(a.tailInterval)
+	defer ticker.Stop()
+	for {
+		columns, rows, err := a.tailRows(ctx, tableName, after)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error tailing table %s: %v", tableName, err)
+			}
+			return
+		}
+		for _, row := range rows {
+			id := row[0]
+			data, err := json.Marshal(rowObjects(columns, [][]interface{}{row[1:]})[0])
+			if err != nil {
+				log.Printf("Error encoding row of table %s: %v", tableName, err)
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %v\ndata: %s\n\n", id, data); err != nil {
+				return
+			}
+			if rowid, ok := id.(int64); ok {
+				after = rowid
+			}
+		}
+		if len(rows) == 0 {
+			// A comment keeps proxies from timing out the idle connection
+			// and notices disconnected clients.
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		if len(rows) == tailBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tailStart returns the rowid after which a tail starts, from the
+// Last-Event-ID header, the after parameter if hasAfter, or the table's
+// current highest rowid. It returns errNoRowid for tables without a rowid.
+func (a *App) tailStart(ctx context.Context, r *http.Request, tableName string, after int64, hasAfter bool) (int64, error) {
+	var maxID int64
+	query := fmt.Sprintf("SELECT COALESCE(MAX(rowid), 0) FROM %s", quoteIdent(tableName))
+	if err := a.conn().QueryRowContext(ctx, query).Scan(&maxID); err != nil {
+		if strings.Contains(err.Error(), "no such column: rowid") {
+			return 0, errNoRowid
+		}
+		return 0, err
+	}
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return strconv.ParseInt(id, 10, 64)
+	}
+	if hasAfter {
+		return after, nil
+	}
+	return maxID, nil
+}
+
+// tailRows returns up to tailBatchSize rows of tableName with a rowid above
+// after, in rowid order. Each row starts with its rowid, which columns doesn't
+// include.
+func (a *App) tailRows(ctx context.Context, tableName string, after int64) ([]Column, [][]interface{}, error) {
+	query := fmt.Sprintf("SELECT rowid, * FROM %s WHERE rowid > ? ORDER BY rowid LIMIT %d", quoteIdent(tableName), tailBatchSize)
+	columns, rows, err := a.executeCustomQuery(ctx, query, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	return columns[1:], rows, nil
+}
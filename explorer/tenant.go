@@ -0,0 +1,40 @@
+// tenant.go
+package explorer
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+)
+
+// tenantParam is the query parameter bound to the value of the -tenant-header
+// request header. database/sql only accepts parameter names starting with a
+// letter, so it is prefixed rather than written with leading underscores.
+const tenantParam = "godatasette_tenant"
+
+// isTenantParam reports whether name is the parameter bound from the tenant
+// header, which query forms and request bodies can't set.
+func (a *App) isTenantParam(name string) bool {
+	return a.tenantHeader != "" && name == tenantParam
+}
+
+// tenantArgs returns the argument binding :godatasette_tenant to the tenant
+// header of r, if -tenant-header is set and query uses the parameter. It
+// fails if the request has no such header, so a query meant to be scoped to
+// a tenant never runs unscoped.
+func (a *App) tenantArgs(r *http.Request, query string) ([]interface{}, error) {
+	if a.tenantHeader == "" {
+		return nil, nil
+	}
+	for _, name := range placeholderNames(query) {
+		if name != tenantParam {
+			continue
+		}
+		tenant := r.Header.Get(a.tenantHeader)
+		if tenant == "" {
+			return nil, fmt.Errorf("The query needs the %s header", a.tenantHeader)
+		}
+		return []interface{}{sql.Named(tenantParam, tenant)}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,216 @@
+// tracing.go
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing sends OpenTelemetry spans to a collector with the OTLP/HTTP
+// exporter. Each request gets a server span, continuing the trace of an
+// incoming traceparent header, and each query a client span beneath it.
+// Without -otel-endpoint a.tracer is nil and none of this runs.
+
+const (
+	// tracerBatchSize is how many spans are sent to the collector at once.
+	tracerBatchSize = 512
+	// tracerQueueSize is how many finished spans wait to be sent; spans
+	// finished while the queue is full are dropped rather than slow requests.
+	tracerQueueSize = 4096
+	// tracerInterval is how often waiting spans are sent.
+	tracerInterval = 5 * time.Second
+	// tracerServiceName is the service.name spans are reported under.
+	tracerServiceName = "godatasette"
+	// tracerStopTimeout is how long stop waits for the last spans to be sent.
+	tracerStopTimeout = 10 * time.Second
+)
+
+// tracer batches finished spans and sends them to an OTLP/HTTP endpoint.
+type tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// span is one traced operation. A nil *span is valid and does nothing, so
+// callers needn't check whether tracing is on.
+type span struct {
+	trace.Span
+}
+
+// newTracer returns a tracer sending to endpoint, the base URL of an OTLP/HTTP
+// collector like http://localhost:4318, to which /v1/traces is added unless it
+// has a path already. Spans are sent in the background until stop.
+func newTracer(endpoint string) (*tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OpenTelemetry endpoint %q: must be an http or https URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(u.Path),
+		otlptracehttp.WithTimeout(10 * time.Second),
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start OpenTelemetry exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(tracerServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the OpenTelemetry resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxExportBatchSize(tracerBatchSize),
+			sdktrace.WithMaxQueueSize(tracerQueueSize),
+			sdktrace.WithBatchTimeout(tracerInterval),
+		),
+	)
+	return &tracer{provider: provider, tracer: provider.Tracer(tracerServiceName)}, nil
+}
+
+// stop sends the spans still queued and stops the tracer. Spans finished
+// later are dropped.
+func (t *tracer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), tracerStopTimeout)
+	defer cancel()
+	t.provider.Shutdown(ctx)
+}
+
+// startSpan starts a span named name as a child of the span in ctx, or as
+// the root of a new trace if there is none, and returns a context carrying
+// it. Without a tracer it returns ctx and a nil span.
+func (a *App) startSpan(ctx context.Context, name string, kind trace.SpanKind) (context.Context, *span) {
+	if a.tracer == nil {
+		return ctx, nil
+	}
+	ctx, s := a.tracer.tracer.Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, &span{s}
+}
+
+// setString sets a string attribute of s.
+func (s *span) setString(key, value string) {
+	if s != nil {
+		s.SetAttributes(attribute.String(key, value))
+	}
+}
+
+// setInt sets an integer attribute of s.
+func (s *span) setInt(key string, value int64) {
+	if s != nil {
+		s.SetAttributes(attribute.Int64(key, value))
+	}
+}
+
+// fail marks the operation of s as failed with err.
+func (s *span) fail(err error) {
+	if s != nil && err != nil {
+		s.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// finish ends s, queueing it to be sent.
+func (s *span) finish() {
+	if s != nil {
+		s.End()
+	}
+}
+
+// traceQuery starts a client span for running query, with its SQL, or only
+// its length with -redact-query-params, since SQL can hold literal values.
+func (a *App) traceQuery(ctx context.Context, query string) (context.Context, *span) {
+	ctx, s := a.startSpan(ctx, "query", trace.SpanKindClient)
+	s.setString("db.system", "sqlite")
+	if a.redactQueryParams {
+		s.setInt("db.statement.length", int64(len(query)))
+	} else {
+		s.setString("db.statement", query)
+	}
+	return ctx, s
+}
+
+// withTracing runs each request in a server span, which continues the trace
+// of its traceparent header if it has a valid one.
+func (a *App) withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, s := a.startSpan(ctx, r.Method, trace.SpanKindServer)
+		s.setString("http.request.method", r.Method)
+		s.setString("url.path", r.URL.Path)
+		if table := tracedTable(r.URL); table != "" {
+			s.setString("db.sql.table", table)
+		}
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		defer func() {
+			s.setInt("http.response.status_code", int64(sw.code))
+			if sw.code >= 500 {
+				s.SetStatus(codes.Error, http.StatusText(sw.code))
+			}
+			s.finish()
+		}()
+		next.ServeHTTP(sw, r.WithContext(ctx))
+	})
+}
+
+// tracedTable returns the table a /table/ or /api/table/ URL is about, or ""
+// for other URLs.
+func tracedTable(u *url.URL) string {
+	path := u.EscapedPath()
+	for _, prefix := range []string{"/table/", "/api/table/"} {
+		if strings.HasPrefix(path, prefix) {
+			name := strings.SplitN(strings.TrimPrefix(path, prefix), "/", 2)[0]
+			if unescaped, err := url.PathUnescape(name); err == nil {
+				return unescaped
+			}
+			return name
+		}
+	}
+	return ""
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.code, sw.wroteHeader = code, true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(p)
+}
+
+// Flush passes flushes on, for streaming handlers like the tail.
+func (sw *statusWriter) Flush() {
+	sw.wroteHeader = true
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
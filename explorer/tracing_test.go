@@ -0,0 +1,182 @@
+// tracing_test.go
+package explorer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// testCollector is an OTLP/HTTP collector recording the spans it receives.
+type testCollector struct {
+	mu       sync.Mutex
+	paths    []string
+	spans    []*tracepb.Span
+	resource []*commonpb.KeyValue
+}
+
+func newTestCollector(t *testing.T) (*testCollector, *httptest.Server) {
+	c := &testCollector{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var req coltracepb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("collector got an invalid request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.paths = append(c.paths, r.URL.Path)
+		for _, rs := range req.ResourceSpans {
+			c.resource = rs.Resource.GetAttributes()
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		out, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+		w.Write(out)
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+// span returns the span named name, failing t if there isn't exactly one.
+func (c *testCollector) span(t *testing.T, name string) *tracepb.Span {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var found *tracepb.Span
+	for _, s := range c.spans {
+		if s.Name == name {
+			if found != nil {
+				t.Fatalf("more than one %s span", name)
+			}
+			found = s
+		}
+	}
+	if found == nil {
+		t.Fatalf("no %s span among %d", name, len(c.spans))
+	}
+	return found
+}
+
+// spanAttr returns the value of the attribute key of attrs as a string, and
+// whether it is set.
+func spanAttr(attrs []*commonpb.KeyValue, key string) (string, bool) {
+	for _, kv := range attrs {
+		if kv.Key != key {
+			continue
+		}
+		switch v := kv.Value.Value.(type) {
+		case *commonpb.AnyValue_StringValue:
+			return v.StringValue, true
+		case *commonpb.AnyValue_IntValue:
+			return fmt.Sprint(v.IntValue), true
+		}
+		return kv.Value.String(), true
+	}
+	return "", false
+}
+
+func TestTracing(t *testing.T) {
+	collector, srv := newTestCollector(t)
+	app := newTestApp(t, "CREATE TABLE t (a); INSERT INTO t VALUES (1);", Config{OTelEndpoint: srv.URL})
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodGet, "/api/query?sql=SELECT+a+FROM+t", nil)
+	req.Header.Set("Traceparent", "00-"+traceID+"-"+parentID+"-01")
+	app.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	app.tracer.stop()
+
+	if len(collector.paths) == 0 || collector.paths[0] != "/v1/traces" {
+		t.Fatalf("spans were sent to %v, want /v1/traces", collector.paths)
+	}
+	if name, _ := spanAttr(collector.resource, "service.name"); name != tracerServiceName {
+		t.Errorf("service.name = %q, want %q", name, tracerServiceName)
+	}
+	server := collector.span(t, http.MethodGet)
+	if got := hex.EncodeToString(server.TraceId); got != traceID {
+		t.Errorf("server span trace ID = %s, want the traceparent's %s", got, traceID)
+	}
+	if got := hex.EncodeToString(server.ParentSpanId); got != parentID {
+		t.Errorf("server span parent = %s, want the traceparent's %s", got, parentID)
+	}
+	if server.Kind != tracepb.Span_SPAN_KIND_SERVER {
+		t.Errorf("server span kind = %v", server.Kind)
+	}
+	if status, _ := spanAttr(server.Attributes, "http.response.status_code"); status != "200" {
+		t.Errorf("http.response.status_code = %q, want 200", status)
+	}
+
+	query := collector.span(t, "query")
+	if string(query.TraceId) != string(server.TraceId) || string(query.ParentSpanId) != string(server.SpanId) {
+		t.Error("query span isn't a child of the server span")
+	}
+	if stmt, _ := spanAttr(query.Attributes, "db.statement"); stmt != "SELECT a FROM t" {
+		t.Errorf("db.statement = %q", stmt)
+	}
+}
+
+func TestTracingTableAndErrors(t *testing.T) {
+	collector, srv := newTestCollector(t)
+	app := newTestApp(t, "CREATE TABLE t (a)", Config{OTelEndpoint: srv.URL + "/custom/path", RedactQueryParams: true})
+
+	app.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/table/t", nil))
+	app.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/query?sql=SELECT+nope+FROM+t", nil))
+	app.tracer.stop()
+
+	if collector.paths[0] != "/custom/path" {
+		t.Errorf("spans were sent to %s, want /custom/path", collector.paths[0])
+	}
+	collector.mu.Lock()
+	spans := collector.spans
+	collector.mu.Unlock()
+	var tables, failed int
+	for _, s := range spans {
+		if table, ok := spanAttr(s.Attributes, "db.sql.table"); ok && table == "t" {
+			tables++
+		}
+		if _, ok := spanAttr(s.Attributes, "db.statement"); ok {
+			t.Errorf("span %s has db.statement despite RedactQueryParams", s.Name)
+		}
+		if s.Name == "query" {
+			if _, ok := spanAttr(s.Attributes, "db.statement.length"); !ok {
+				t.Error("query span has no db.statement.length")
+			}
+			if s.Status.GetCode() == tracepb.Status_STATUS_CODE_ERROR {
+				failed++
+			}
+		}
+	}
+	if tables == 0 {
+		t.Error("no span has db.sql.table for /api/table/t")
+	}
+	if failed != 1 {
+		t.Errorf("%d failed query spans, want 1", failed)
+	}
+}
+
+func TestTracingOff(t *testing.T) {
+	app := newTestApp(t, "CREATE TABLE t (a)", Config{})
+	if app.tracer != nil {
+		t.Error("tracer started without OTelEndpoint")
+	}
+	if _, err := newTracer("localhost:4318"); err == nil {
+		t.Error("newTracer accepted an endpoint without a scheme")
+	}
+}
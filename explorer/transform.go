@@ -0,0 +1,132 @@
+// transform.go
+package explorer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RowTransformer post-processes the rows of an API response, e.g. to redact
+// values or add a computed column, without changing the query. Transformers
+// are chosen by name with ?_transform= from Config.Transformers.
+type RowTransformer interface {
+	// Columns returns the columns of the transformed rows, given those of the
+	// query. Transformers that keep the columns return them unchanged.
+	Columns(columns []Column) []Column
+	// TransformRow returns row, whose values are in the order of columns, as
+	// a row of the transformed columns. It may modify row in place. SQL NULL
+	// is a value for which IsNull is true.
+	TransformRow(columns []Column, row []interface{}) []interface{}
+}
+
+// DefaultTransformers are the transformers ?_transform= can select unless
+// Config.Transformers says otherwise.
+var DefaultTransformers = map[string]RowTransformer{
+	"redact_emails": redactEmails{},
+	"null_count":    nullCount{},
+}
+
+// IsNull reports whether v, a value of a row passed to a RowTransformer, is
+// SQL NULL.
+func IsNull(v interface{}) bool {
+	switch v.(type) {
+	case nullValue, nil:
+		return true
+	}
+	return false
+}
+
+// emailInTextRe matches email addresses anywhere in text.
+var emailInTextRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// redactEmails replaces every email address in text values with
+// "[redacted]", keeping the rest of the text.
+type redactEmails struct{}
+
+func (redactEmails) Columns(columns []Column) []Column { return columns }
+
+func (redactEmails) TransformRow(columns []Column, row []interface{}) []interface{} {
+	for i, value := range row {
+		if s, ok := value.(string); ok {
+			row[i] = emailInTextRe.ReplaceAllString(s, "[redacted]")
+		}
+	}
+	return row
+}
+
+// nullCount adds a null_count column with the number of NULL values in the
+// row, for spotting incomplete rows.
+type nullCount struct{}
+
+func (nullCount) Columns(columns []Column) []Column {
+	return append(columns[:len(columns):len(columns)], Column{Name: "null_count", Type: "INTEGER"})
+}
+
+func (nullCount) TransformRow(columns []Column, row []interface{}) []interface{} {
+	var n int64
+	for _, value := range row {
+		if IsNull(value) {
+			n++
+		}
+	}
+	return append(row, n)
+}
+
+// transformParam reads ?_transform=, a comma-separated list of transformer
+// names applied in order, failing params for unknown names.
+func (a *App) transformParam(params *queryParams) []RowTransformer {
+	transformers, err := a.lookupTransformers(params.get("_transform"))
+	if err != nil {
+		params.fail("_transform", err.Error())
+	}
+	return transformers
+}
+
+// lookupTransformers returns the transformers named in names, a
+// comma-separated list, in order.
+func (a *App) lookupTransformers(names string) ([]RowTransformer, error) {
+	if names == "" {
+		return nil, nil
+	}
+	var transformers []RowTransformer
+	for _, name := range strings.Split(names, ",") {
+		t, ok := a.transformers[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown transformer '%s'; available: %s", strings.TrimSpace(name), strings.Join(a.transformerNames(), ", "))
+		}
+		transformers = append(transformers, t)
+	}
+	return transformers, nil
+}
+
+// transformerNames returns the names of the available transformers, sorted.
+func (a *App) transformerNames() []string {
+	names := make([]string, 0, len(a.transformers))
+	for name := range a.transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// transformRows applies transformers to rows in order. Rows are copied first,
+// since they may be shared with the query cache.
+func transformRows(transformers []RowTransformer, columns []Column, rows [][]interface{}) ([]Column, [][]interface{}) {
+	if len(transformers) == 0 {
+		return columns, rows
+	}
+	out := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		out[i] = append([]interface{}(nil), row...)
+	}
+	for _, t := range transformers {
+		next := t.Columns(columns)
+		for i, row := range out {
+			out[i] = t.TransformRow(columns, row)
+		}
+		columns = next
+	}
+	return columns, out
+}
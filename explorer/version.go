@@ -0,0 +1,60 @@
+// version.go
+package explorer
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// Build information, set at build time with e.g.
+//
+//	go build -ldflags "-X godatasette/explorer.Version=v1.2.0 -X godatasette/explorer.Commit=$(git rev-parse HEAD) -X godatasette/explorer.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When they are left unset, Commit and BuildDate fall back to the VCS details
+// the Go toolchain embeds in binaries built from a git checkout.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// BuildInfo describes the running build.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// GetBuildInfo returns the version, commit and build date of the running
+// binary, using "unknown" for anything that isn't available.
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: "unknown"}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = bi.GoVersion
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// handleAPIVersion returns the build information as JSON.
+func (a *App) handleAPIVersion(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		a.respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	a.respondWithJSON(w, http.StatusOK, GetBuildInfo())
+}
@@ -0,0 +1,58 @@
+// vtable.go
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// virtualTableRe captures the module name of a CREATE VIRTUAL TABLE statement.
+var virtualTableRe = regexp.MustCompile(`(?is)^\s*CREATE\s+VIRTUAL\s+TABLE\s+.+?\s+USING\s+(\w+)`)
+
+// shadowSuffixes lists, for the virtual table modules that keep their data in
+// ordinary "shadow" tables, the suffixes of those tables, which are named
+// <virtual table>_<suffix>. SQLite can only tell shadow tables apart itself
+// when the module is compiled in, so they are recognized by name instead.
+var shadowSuffixes = map[string][]string{
+	"fts3":      {"content", "segments", "segdir", "docsize", "stat"},
+	"fts4":      {"content", "segments", "segdir", "docsize", "stat"},
+	"fts5":      {"data", "idx", "content", "docsize", "config"},
+	"rtree":     {"node", "parent", "rowid"},
+	"rtree_i32": {"node", "parent", "rowid"},
+	"geopoly":   {"node", "parent", "rowid"},
+}
+
+// virtualTableModule returns the lowercased module name of a virtual table
+// from its CREATE statement, or "" for an ordinary table.
+func virtualTableModule(ddl string) string {
+	m := virtualTableRe.FindStringSubmatch(ddl)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(m[1])
+}
+
+// shadowTables returns the names of the shadow tables backing the virtual
+// tables of schema.
+func (a *App) shadowTables(ctx context.Context, schema string) ([]string, error) {
+	query := fmt.Sprintf("SELECT name, sql FROM %s.sqlite_master WHERE type='table' AND sql LIKE 'CREATE VIRTUAL TABLE%%'", quoteIdent(schema))
+	rows, err := a.conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name, ddl string
+		if err := rows.Scan(&name, &ddl); err != nil {
+			return nil, err
+		}
+		for _, suffix := range shadowSuffixes[virtualTableModule(ddl)] {
+			names = append(names, name+"_"+suffix)
+		}
+	}
+	return names, rows.Err()
+}
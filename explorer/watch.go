@@ -0,0 +1,107 @@
+// watch.go
+package explorer
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"time"
+)
+
+// watchInterval is how often -watch-db checks whether the file was replaced.
+const watchInterval = 2 * time.Second
+
+// drainDelay is how long a replaced connection pool is kept open so requests
+// that fetched it just before the swap can still start their queries.
+// sql.DB.Close itself waits for queries that are already running.
+const drainDelay = 30 * time.Second
+
+// conn returns the current database connection pool.
+func (a *App) conn() *sql.DB {
+	a.dbMu.RLock()
+	defer a.dbMu.RUnlock()
+	return a.db
+}
+
+// attachments returns the databases attached to the current connection pool.
+func (a *App) attachments() []attachedDB {
+	a.dbMu.RLock()
+	defer a.dbMu.RUnlock()
+	return a.attached
+}
+
+// WatchDB polls the database file and reopens it whenever the file at dbPath
+// is replaced by a different one (e.g. an ETL job renaming a new snapshot into
+// place). In-place writes to the same file don't need a reopen, since SQLite
+// reads them through the existing handle. It runs until the program exits, so
+// start it in its own goroutine. Apps created by NewAppWithDB aren't watched,
+// and neither are gzipped databases.
+func (a *App) WatchDB() {
+	if !a.ownsDB {
+		return
+	}
+	if a.compressed {
+		log.Printf("Not watching %s: replacing compressed databases at runtime isn't supported", a.dbPath)
+		return
+	}
+	a.watchDB(watchInterval)
+}
+
+// watchDB implements WatchDB, checking the file every interval.
+func (a *App) watchDB(interval time.Duration) {
+	current, err := os.Stat(a.dbPath)
+	if err != nil {
+		log.Printf("Cannot watch database file: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		info, err := os.Stat(a.dbPath)
+		if err != nil {
+			continue // Mid-replacement, or temporarily missing; try again later
+		}
+		if os.SameFile(current, info) {
+			continue
+		}
+
+		a.reopenMu.Lock()
+		attached := a.attachments()
+		db, err := openDB(a.driver, a.dbPath, a.writable, a.dsnParams, attached)
+		if err != nil {
+			a.reopenMu.Unlock()
+			log.Printf("Database file changed but could not be reopened: %v", err)
+			continue
+		}
+		current = info
+		a.replaceDB(db, attached)
+		a.reopenMu.Unlock()
+		log.Printf("Database file replaced, reconnected to %s", a.dbPath)
+	}
+}
+
+// replaceDB swaps in db, with attached attached to it, as the connection pool
+// and drops what was cached from the old one, which is closed, along with the
+// pools of databaseConn, once requests still using it have had time to start
+// their queries. Callers hold reopenMu from reading the attachments they open
+// db with until it is swapped in, so concurrent reopens don't drop each
+// other's attachments.
+func (a *App) replaceDB(db *sql.DB, attached []attachedDB) {
+	a.dbMu.Lock()
+	old := a.db
+	a.db = db
+	a.attached = attached
+	a.dbMu.Unlock()
+	scoped := a.takeScopedConns()
+	a.schema.reset()
+	a.relations.reset()
+
+	go func() {
+		time.Sleep(drainDelay)
+		old.Close()
+		for _, db := range scoped {
+			db.Close()
+		}
+	}()
+}
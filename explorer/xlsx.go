@@ -0,0 +1,172 @@
+// xlsx.go
+package explorer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// xlsxParts are the fixed parts of a one-sheet workbook, by path in the zip.
+var xlsxParts = []struct{ path, xml string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// writeXLSX writes rows as an Excel workbook with one sheet named sheet,
+// with a header row of column names. Numbers are written as numbers, NULL
+// as an empty cell and everything else as text, so Excel doesn't reinterpret
+// values that merely look like dates or numbers.
+func writeXLSX(w io.Writer, sheet string, columns []Column, rows [][]interface{}) error {
+	x, err := newXLSXWriter(w, sheet, columns)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		x.writeRow(row)
+	}
+	return x.close()
+}
+
+// xlsxWriter writes the workbook of writeXLSX a row at a time, so that a
+// large export isn't held in memory. The sheet is the last part of the zip,
+// so its rows go straight into it.
+type xlsxWriter struct {
+	z     *zip.Writer
+	sheet *bufio.Writer
+	n     int // Rows written so far, including the header
+}
+
+// newXLSXWriter writes the fixed parts of the workbook and the header row of
+// its sheet to w.
+func newXLSXWriter(w io.Writer, sheet string, columns []Column) (*xlsxWriter, error) {
+	z := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		if err := writeZipPart(z, part.path, part.xml); err != nil {
+			return nil, err
+		}
+	}
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + xmlText(xlsxSheetName(sheet)) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	if err := writeZipPart(z, "xl/workbook.xml", workbook); err != nil {
+		return nil, err
+	}
+
+	f, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxWriter{z: z, sheet: bufio.NewWriter(f)}
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	header := make([]interface{}, len(columns))
+	for i, col := range columns {
+		header[i] = col.Name
+	}
+	return x, x.writeRow(header)
+}
+
+// writeRow adds a row to the sheet. Write errors are reported by close.
+func (x *xlsxWriter) writeRow(row []interface{}) error {
+	x.n++
+	writeXLSXRow(x.sheet, x.n, row)
+	return nil
+}
+
+// close ends the sheet and the workbook.
+func (x *xlsxWriter) close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.z.Close()
+}
+
+// writeXLSXRow writes row number n of a worksheet.
+func writeXLSXRow(b *bufio.Writer, n int, row []interface{}) {
+	fmt.Fprintf(b, `<row r="%d">`, n)
+	for i, value := range row {
+		ref := xlsxColumn(i) + strconv.Itoa(n)
+		switch v := value.(type) {
+		case nullValue, nil:
+		case int64:
+			fmt.Fprintf(b, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				// Excel numbers are finite, so write these as text.
+				fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, fmt.Sprint(v))
+				continue
+			}
+			fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'g', -1, 64))
+		default:
+			fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlText(fmt.Sprint(v)))
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+// xlsxColumn returns the letters of the zero-based column i: A, B, ..., Z,
+// AA, AB and so on.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxSheetName makes name a valid sheet name: at most 31 characters, none
+// of them one of []:*?/\ and not empty.
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if strings.TrimSpace(name) == "" {
+		return "Sheet1"
+	}
+	return name
+}
+
+// xmlText escapes s for XML text and attribute values. Characters XML can't
+// hold, like most control characters, become U+FFFD.
+func xmlText(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeZipPart adds a file to z.
+func writeZipPart(z *zip.Writer, path, content string) error {
+	f, err := z.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
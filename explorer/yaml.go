@@ -0,0 +1,91 @@
+// yaml.go
+package explorer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlPlainRe matches strings that can be written as plain YAML scalars; the
+// rest are double-quoted.
+var yamlPlainRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_ ./-]*$`)
+
+// yamlReserved are plain scalars that YAML parsers read as something other
+// than a string, compared case-insensitively.
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true,
+}
+
+// respondWithYAML writes rows as YAML; see formatYAML.
+func (a *App) respondWithYAML(w http.ResponseWriter, columns []Column, rows [][]interface{}) {
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(formatYAML(columns, rows)))
+}
+
+// formatYAML formats rows as a YAML sequence of mappings keyed by column
+// name, in column order: the objects shape of the JSON API, with the same
+// names for columns that share one (see uniqueColumnNames).
+func formatYAML(columns []Column, rows [][]interface{}) string {
+	names := uniqueColumnNames(columns)
+
+	var b strings.Builder
+	if len(rows) == 0 || len(names) == 0 {
+		b.WriteString("[]\n")
+	}
+	for _, row := range rows {
+		b.WriteString(yamlRow(names, row))
+	}
+	return b.String()
+}
+
+// yamlRow formats row as one item of the sequence formatYAML writes, keyed
+// by names.
+func yamlRow(names []string, row []interface{}) string {
+	var b strings.Builder
+	prefix := "- "
+	for i, name := range names {
+		b.WriteString(prefix + yamlScalar(name) + ": " + yamlScalar(row[i]) + "\n")
+		prefix = "  "
+	}
+	return b.String()
+}
+
+// yamlScalar formats a value as a YAML scalar. SQL NULL is YAML null, so it
+// can't be mistaken for the text "NULL". Strings are written plain when that
+// reads back as the same string, and double-quoted otherwise.
+func yamlScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil, nullValue:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return ".nan"
+		case math.IsInf(v, 1):
+			return ".inf"
+		case math.IsInf(v, -1):
+			return "-.inf"
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		if yamlPlainRe.MatchString(v) && !strings.HasSuffix(v, " ") && !yamlReserved[strings.ToLower(v)] {
+			return v
+		}
+		// JSON strings are valid double-quoted YAML scalars.
+		quoted, _ := json.Marshal(v)
+		return string(quoted)
+	default:
+		return yamlScalar(fmt.Sprint(v))
+	}
+}
@@ -2,401 +2,352 @@
 package main
 
 import (
-	"database/sql"
-	"embed"
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
-	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
-)
-
-//go:embed templates
-var templateFS embed.FS
-
-// App holds application-wide dependencies, like the database connection.
-type App struct {
-	db        *sql.DB
-	templates *template.Template
-	dbPath    string
-}
-
-// Table represents a single database table.
-type Table struct {
-	Name       string
-	RowCount   int64
-	ViewURL    string
-	APIDataURL string
-}
-
-// PageData is the structure passed to HTML templates.
-type PageData struct {
-	DBName       string
-	Tables       []Table
-	CurrentTable string
-	Columns      []string
-	Rows         [][]interface{}
-	Query        string
-	Error        string
-	CurrentPage  int
-	NextPage     int
-	PrevPage     int
-	HasNextPage  bool
-	TotalPages   int
-}
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
-const rowsPerPage = 50
+	"godatasette/explorer"
+)
 
 func main() {
 	// --- Command-Line Flags ---
-	dbPath := flag.String("db", "", "Path to the SQLite database file (required)")
+	var dbPaths stringList
+	flag.Var(&dbPaths, "db", "Path to the SQLite database file, optionally as label=path (required unless -db-glob is set; repeat with -attach to attach more)")
 	port := flag.Int("port", 8080, "Port to run the web server on")
+	debug := flag.Bool("debug", false, "Include internal error details in error responses")
+	timeFormat := flag.String("time-format", time.RFC3339, "Go time layout used to display DATE/DATETIME/TIMESTAMP columns")
+	templatesDir := flag.String("templates-dir", "", "Directory of HTML templates overriding the built-in ones")
+	writable := flag.Bool("writable", false, "Open the database read-write and enable the import API")
+	cacheSize := flag.Int("query-cache-size", 0, "Number of custom query results to cache (0 disables caching)")
+	dsnParams := flag.String("dsn-params", "", "Extra SQLite URI parameters, e.g. \"immutable=1&cache=shared\"")
+	watchDB := flag.Bool("watch-db", false, "Reopen the database when the file is replaced on disk")
+	attach := flag.Bool("attach", false, "Attach the second and later -db files read-only for cross-database queries")
+	allowDownload := flag.Bool("allow-db-download", false, "Allow downloading a snapshot of the database at /api/download.db")
+	maxBodyBytes := flag.Int64("max-body-bytes", 1<<20, "Maximum request body size in bytes (0 for no limit)")
+	maxRows := flag.Int("max-rows", 100000, "Maximum number of rows a custom query or table export returns (0 for no limit)")
+	corsOrigins := flag.String("cors-origins", "", "Comma-separated origins allowed to call the API, or * for any")
+	corsMaxAge := flag.Int("cors-max-age", 0, "Seconds browsers may cache CORS preflight responses (0 to omit)")
+	corsCredentials := flag.Bool("cors-credentials", false, "Allow credentialed cross-origin API requests (requires explicit -cors-origins)")
+	geoLatCol := flag.String("geo-lat-col", "", "Latitude column for ?_format=geojson (default: latitude or lat)")
+	geoLngCol := flag.String("geo-lng-col", "", "Longitude column for ?_format=geojson (default: longitude, lng, lon or long)")
+	tailInterval := flag.Duration("tail-interval", 2*time.Second, "How often /table/{name}/tail polls for new rows")
+	metadataPath := flag.String("metadata", "", "JSON file of per-table settings, such as default sort columns")
+	showShadowTables := flag.Bool("show-shadow-tables", false, "List the internal tables backing FTS and R*Tree virtual tables")
+	admin := flag.Bool("admin", false, "Enable the /api/admin/ endpoints, protected by -admin-token")
+	adminToken := flag.String("admin-token", "", "Bearer token for the admin endpoints (default $GODATASETTE_ADMIN_TOKEN)")
+	allowJSONP := flag.Bool("allow-jsonp", false, "Accept ?_callback= on API GET requests to return JSONP")
+	trustedProxies := flag.String("trusted-proxies", "", "Comma-separated CIDR ranges or IPs of proxies whose X-Forwarded-For and X-Real-IP headers are trusted")
+	blockedFunctions := flag.String("blocked-functions", strings.Join(explorer.DefaultBlockedFunctions, ","), "Comma-separated SQL functions custom queries may not call (empty to allow all)")
+	description := flag.String("description", "", "Markdown text shown above the table list on the index page")
+	descriptionFile := flag.String("description-file", "", "Markdown file shown above the table list on the index page")
+	slowQueryThreshold := flag.Duration("slow-query-threshold", time.Second, "Log queries taking longer than this as warnings with their SQL and parameters (0 to disable)")
+	redactQueryParams := flag.Bool("redact-query-params", false, "Log query parameters as ? instead of their values")
+	noCustomQuery := flag.Bool("no-custom-query", false, "Disable arbitrary SQL queries: /query, /api/query and /db/{name}/query")
+	healthPingInterval := flag.Duration("health-ping-interval", 0, "How often to ping the database, reopening it on failure with -watch-db (0 disables)")
+	maxQueryLength := flag.Int("max-query-length", 100000, "Longest custom query accepted, in characters (0 for no limit)")
+	dbGlob := flag.String("db-glob", "", "Pattern of database files to attach, e.g. '/data/*.db', rescanned on SIGHUP")
+	dbGlobInterval := flag.Duration("db-glob-interval", 0, "How often to rescan -db-glob for added and removed files (0 for only on SIGHUP)")
+	tenantHeader := flag.String("tenant-header", "", "Request header bound to the :godatasette_tenant query parameter, e.g. X-Tenant-Id")
+	execFile := flag.String("exec-file", "", "Run the SQL query in this file, write the result to stdout and exit instead of serving")
+	exportTable := flag.String("export-table", "", "Write every row of this table to -out, or stdout, and exit instead of serving")
+	exportAll := flag.Bool("export-all", false, "Write every table to its own file in the -out directory and exit instead of serving")
+	out := flag.String("out", "", "File for -exec-file and -export-table (default stdout), or directory for -export-all")
+	format := flag.String("format", "json", "Output format of -exec-file and the exports: json, yaml, csv or xlsx")
+	streamThreshold := flag.Int("stream-threshold", 1<<20, "API responses up to this many bytes are sent with a Content-Length, larger ones are streamed (0 streams all)")
+	exportTimeout := flag.Duration("export-timeout", 10*time.Minute, "Time exports and streams, like CSV responses, downloads and tails, have to send their response (0 for no limit)")
+	otelEndpoint := flag.String("otel-endpoint", "", "OpenTelemetry collector to send traces to over OTLP/HTTP, e.g. http://localhost:4318 (tracing is off if empty)")
+	busyTimeout := flag.Duration("busy-timeout", 5*time.Second, "How long queries wait for a lock held by another process, e.g. a writer, before failing")
+	defaultRoute := flag.String("default-route", "", "Where / redirects: auto for the table of a single-table database, or a path like /table/users; the index stays at /tables")
+	driver := flag.String("driver", explorer.DefaultDriver, "SQLite driver: sqlite3 (mattn/go-sqlite3, cgo) or sqlite (modernc.org/sqlite, pure Go, built with -tags modernc)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file; with -tls-key, serve HTTPS and HTTP/2")
+	tlsKey := flag.String("tls-key", "", "TLS private key file for -tls-cert")
+	h2cFlag := flag.Bool("h2c", false, "Also accept cleartext HTTP/2 (h2c), for a proxy that speaks it; not with -tls-cert")
+	deepPageMode := flag.String("deep-page-mode", "warn", "How to serve table pages past row 100000: warn, error or rowid")
+	version := flag.Bool("version", false, "Print version and build information and exit")
 	flag.Parse()
 
-	if *dbPath == "" {
-		log.Println("Error: -db flag is required.")
-		flag.Usage()
-		os.Exit(1)
+	if *version {
+		info := explorer.GetBuildInfo()
+		fmt.Printf("godatasette %s (commit %s, built %s, %s)\n", info.Version, info.Commit, info.BuildDate, info.GoVersion)
+		return
 	}
 
-	// --- Application Setup ---
-	app, err := NewApp(*dbPath)
-	if err != nil {
-		log.Fatalf("Failed to initialize application: %v", err)
+	modes := 0
+	for _, set := range []bool{*execFile != "", *exportTable != "", *exportAll} {
+		if set {
+			modes++
+		}
 	}
-	defer app.db.Close()
-
-	// --- HTTP Server Setup ---
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", app.handleIndex)
-	mux.HandleFunc("/table/", app.handleTable)
-	mux.HandleFunc("/query", app.handleQuery)
-
-	// API endpoints
-	mux.HandleFunc("/api/tables", app.handleAPITables)
-	mux.HandleFunc("/api/table/", app.handleAPITableData)
-	mux.HandleFunc("/api/query", app.handleAPIQuery)
-
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", *port),
-		Handler:      mux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	if modes > 1 {
+		log.Println("Error: -exec-file, -export-table and -export-all can't be combined.")
+		os.Exit(1)
 	}
-
-	log.Printf("Starting GoDB-Explorer for '%s'", filepath.Base(*dbPath))
-	log.Printf("Server listening on http://localhost:%d", *port)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Println("Error: -tls-cert and -tls-key must be set together.")
+		os.Exit(1)
 	}
-}
-
-// NewApp creates and initializes a new App instance.
-func NewApp(dbPath string) (*App, error) {
-	// Check if the database file exists
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("database file not found at path: %s", dbPath)
+	if *h2cFlag && *tlsCert != "" {
+		log.Println("Error: -h2c is cleartext HTTP/2; over TLS, HTTP/2 is negotiated without it.")
+		os.Exit(1)
+	}
+	if *exportAll && *out == "" {
+		log.Println("Error: -export-all requires -out.")
+		os.Exit(1)
 	}
 
-	// Connect to the SQLite database
-	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", dbPath))
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+	if len(dbPaths) == 0 && *dbGlob == "" {
+		log.Println("Error: -db or -db-glob flag is required.")
+		flag.Usage()
+		os.Exit(1)
 	}
-	if err = db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	if len(dbPaths) > 1 && !*attach {
+		log.Println("Error: multiple -db files require -attach.")
+		os.Exit(1)
 	}
-
-	// Parse HTML templates from the embedded filesystem
-	templates, err := template.ParseFS(templateFS, "templates/*.html")
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	// Without -db, NewApp makes the first -db-glob match the main database.
+	if len(dbPaths) == 0 {
+		dbPaths = stringList{""}
 	}
-
-	return &App{
-		db:        db,
-		templates: templates,
-		dbPath:    dbPath,
-	}, nil
-}
-
-// --- HTTP Handlers (HTML) ---
-
-// handleIndex displays the homepage with a list of tables.
-func (a *App) handleIndex(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
+	labels := make([]string, len(dbPaths))
+	for i, arg := range dbPaths {
+		if arg == "" {
+			continue
+		}
+		label, path, err := explorer.ParseDBArg(arg)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			os.Exit(1)
+		}
+		labels[i], dbPaths[i] = label, path
 	}
-
-	tables, err := a.getTables()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list tables: %v", err), http.StatusInternalServerError)
-		return
+	dbPath := dbPaths[0]
+	if *adminToken == "" {
+		*adminToken = os.Getenv("GODATASETTE_ADMIN_TOKEN")
 	}
 
-	data := PageData{
-		DBName: filepath.Base(a.dbPath),
-		Tables: tables,
-	}
-	a.renderTemplate(w, "index.html", data)
-}
+	// --- Application Setup ---
+	app, err := explorer.NewApp(explorer.Config{
+		DBPath:        dbPath,
+		Name:          labels[0],
+		Debug:         *debug,
+		TimeFormat:    *timeFormat,
+		TemplatesDir:  *templatesDir,
+		Writable:      *writable,
+		CacheSize:     *cacheSize,
+		DSNParams:     *dsnParams,
+		DeepPageMode:  *deepPageMode,
+		AttachPaths:   dbPaths[1:],
+		AttachNames:   labels[1:],
+		MaxRows:       *maxRows,
+		AllowDownload: *allowDownload,
+		MaxBodyBytes:  *maxBodyBytes,
 
-// handleTable displays data for a specific table with pagination.
-func (a *App) handleTable(w http.ResponseWriter, r *http.Request) {
-	tableName := strings.TrimPrefix(r.URL.Path, "/table/")
-	if tableName == "" {
-		http.Error(w, "Table name not specified", http.StatusBadRequest)
-		return
-	}
+		CORSOrigins:     splitList(*corsOrigins),
+		CORSMaxAge:      *corsMaxAge,
+		CORSCredentials: *corsCredentials,
 
-	page := 1
-	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
-		page = p
-	}
+		GeoLatCol: *geoLatCol,
+		GeoLngCol: *geoLngCol,
 
-	columns, rows, totalRows, err := a.getTableData(tableName, page)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to fetch table data: %v", err), http.StatusInternalServerError)
-		return
-	}
+		TailInterval: *tailInterval,
+		MetadataPath: *metadataPath,
 
-	totalPages := int(totalRows-1)/rowsPerPage + 1
-	if totalRows == 0 {
-		totalPages = 0
-	}
+		ShowShadowTables: *showShadowTables,
 
-	data := PageData{
-		DBName:       filepath.Base(a.dbPath),
-		CurrentTable: tableName,
-		Columns:      columns,
-		Rows:         rows,
-		CurrentPage:  page,
-		NextPage:     page + 1,
-		PrevPage:     page - 1,
-		HasNextPage:  page < totalPages,
-		TotalPages:   totalPages,
-	}
+		Admin:      *admin,
+		AdminToken: *adminToken,
+		AllowJSONP: *allowJSONP,
 
-	a.renderTemplate(w, "table.html", data)
-}
+		TrustedProxies: splitList(*trustedProxies),
 
-// handleQuery displays a form for custom SQL and shows results.
-func (a *App) handleQuery(w http.ResponseWriter, r *http.Request) {
-	query := r.FormValue("sql")
-	data := PageData{
-		DBName: filepath.Base(a.dbPath),
-		Query:  query,
-	}
+		BlockedFunctions: append([]string{}, splitList(*blockedFunctions)...),
 
-	if r.Method == http.MethodPost && query != "" {
-		// Basic security: only allow SELECT statements.
-		if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
-			data.Error = "Only SELECT queries are allowed."
-		} else {
-			columns, rows, err := a.executeCustomQuery(query)
-			if err != nil {
-				data.Error = err.Error()
-			} else {
-				data.Columns = columns
-				data.Rows = rows
-			}
-		}
-	}
+		Description:     *description,
+		DescriptionFile: *descriptionFile,
 
-	a.renderTemplate(w, "query.html", data)
-}
+		SlowQueryThreshold: *slowQueryThreshold,
+		RedactQueryParams:  *redactQueryParams,
 
-// --- HTTP Handlers (JSON API) ---
+		NoCustomQuery:  *noCustomQuery,
+		MaxQueryLength: *maxQueryLength,
 
-func (a *App) handleAPITables(w http.ResponseWriter, r *http.Request) {
-	tables, err := a.getTables()
-	if err != nil {
-		a.respondWithError(w, http.StatusInternalServerError, "Failed to get tables")
-		return
-	}
-	a.respondWithJSON(w, http.StatusOK, tables)
-}
+		DBGlob: *dbGlob,
 
-func (a *App) handleAPITableData(w http.ResponseWriter, r *http.Request) {
-	tableName := strings.TrimPrefix(r.URL.Path, "/api/table/")
-	page := 1
-	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
-		page = p
-	}
+		TenantHeader: *tenantHeader,
 
-	columns, rows, totalRows, err := a.getTableData(tableName, page)
-	if err != nil {
-		a.respondWithError(w, http.StatusInternalServerError, "Failed to get table data")
-		return
-	}
+		StreamThreshold: *streamThreshold,
 
-	response := map[string]interface{}{
-		"tableName":   tableName,
-		"page":        page,
-		"rowsPerPage": rowsPerPage,
-		"totalRows":   totalRows,
-		"columns":     columns,
-		"rows":        rows,
-	}
-	a.respondWithJSON(w, http.StatusOK, response)
-}
+		WriteTimeout:  10 * time.Second,
+		ExportTimeout: *exportTimeout,
 
-func (a *App) handleAPIQuery(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("sql")
-	if query == "" {
-		a.respondWithError(w, http.StatusBadRequest, "Missing 'sql' query parameter")
-		return
-	}
+		OTelEndpoint: *otelEndpoint,
 
-	if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
-		a.respondWithError(w, http.StatusForbidden, "Only SELECT queries are allowed.")
-		return
-	}
+		BusyTimeout: *busyTimeout,
+
+		DefaultRoute: *defaultRoute,
 
-	columns, rows, err := a.executeCustomQuery(query)
+		Driver: *driver,
+	})
 	if err != nil {
-		a.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Query execution failed: %v", err))
-		return
+		log.Fatalf("Failed to initialize application: %v", err)
 	}
+	defer app.Close()
 
-	response := map[string]interface{}{
-		"query":   query,
-		"columns": columns,
-		"rows":    rows,
+	if modes > 0 {
+		os.Exit(runOnce(app, *execFile, *exportTable, *exportAll, *out, *format))
 	}
-	a.respondWithJSON(w, http.StatusOK, response)
-}
 
-// --- Database Logic ---
+	// Shut down cleanly on Ctrl-C or SIGTERM, so the deferred app.Close
+	// runs and removes any decompressed temporary database files.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-// getTables retrieves all user-defined tables from the database.
-func (a *App) getTables() ([]Table, error) {
-	query := "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name;"
-	rows, err := a.db.Query(query)
-	if err != nil {
-		return nil, err
+	if *watchDB {
+		go app.WatchDB()
+	}
+	var background sync.WaitGroup
+	if *healthPingInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			app.HealthPing(ctx, *healthPingInterval, *watchDB)
+		}()
+	}
+	if *dbGlob != "" {
+		rescan := make(chan os.Signal, 1)
+		signal.Notify(rescan, syscall.SIGHUP)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			app.WatchDBGlob(ctx, *dbGlobInterval, rescan)
+		}()
 	}
-	defer rows.Close()
-
-	var tables []Table
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return nil, err
-		}
 
-		// Get row count for each table
-		var count int64
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %q", name)
-		err := a.db.QueryRow(countQuery).Scan(&count)
-		if err != nil {
-			log.Printf("Could not count rows for table %s: %v", name, err)
-			count = -1 // Indicate an error
+	// --- HTTP Server Setup ---
+	server := &http.Server{
+		Addr:        fmt.Sprintf(":%d", *port),
+		Handler:     app.Handler(),
+		ReadTimeout: 5 * time.Second,
+		IdleTimeout: 120 * time.Second,
+		// The handler sets each request's write deadline itself, so exports
+		// can get longer than other requests; see explorer.ConnContext.
+		// It can't for HTTP/2, whose streams share a connection, so they
+		// get WriteTimeout, the export timeout, which net/http applies to
+		// each stream.
+		ConnContext:  explorer.ConnContext,
+		WriteTimeout: *exportTimeout,
+	}
+	scheme := "http"
+	if *tlsCert != "" {
+		// ServeTLS adds HTTP/2 support when TLSNextProto is nil; listing
+		// "h2" first makes clients that offer both prefer it.
+		server.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			NextProtos: []string{"h2", "http/1.1"},
 		}
-
-		tables = append(tables, Table{
-			Name:       name,
-			RowCount:   count,
-			ViewURL:    fmt.Sprintf("/table/%s", name),
-			APIDataURL: fmt.Sprintf("/api/table/%s", name),
-		})
+		scheme = "https"
+	}
+	if *h2cFlag {
+		// h2c.NewHandler serves HTTP/2 connections, both with prior
+		// knowledge and upgraded from HTTP/1.1, with the server's settings,
+		// WriteTimeout included; HTTP/1.1 requests pass straight through.
+		server.Handler = h2c.NewHandler(server.Handler, &http2.Server{IdleTimeout: server.IdleTimeout})
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	source := filepath.Base(dbPath)
+	if dbPath == "" {
+		source = *dbGlob
+	}
+	log.Printf("Starting GoDB-Explorer for '%s'", source)
+	log.Printf("Server listening on %s://localhost:%d", scheme, *port)
+	if *tlsCert != "" {
+		err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Server failed: %v", err)
 	}
-	return tables, nil
+	// Let the health pings and rescans finish before app.Close closes the database.
+	stop()
+	background.Wait()
 }
 
-// getTableData retrieves paginated data for a given table.
-func (a *App) getTableData(tableName string, page int) (columns []string, rows [][]interface{}, totalRows int64, err error) {
-	// First, get the total number of rows for pagination
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %q", tableName)
-	err = a.db.QueryRow(countQuery).Scan(&totalRows)
-	if err != nil {
-		return
-	}
-
-	// Then, fetch the paginated data
-	offset := (page - 1) * rowsPerPage
-	query := fmt.Sprintf("SELECT * FROM %q LIMIT %d OFFSET %d", tableName, rowsPerPage, offset)
+// stringList is a flag.Value collecting the values of a repeatable flag.
+type stringList []string
 
-	columns, rows, err = a.executeCustomQuery(query)
-	return
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
 }
 
-// executeCustomQuery runs a given SQL query and returns the results.
-func (a *App) executeCustomQuery(query string) ([]string, [][]interface{}, error) {
-	rows, err := a.db.Query(query)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer rows.Close()
-
-	columns, err := rows.Columns()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	var results [][]interface{}
-	for rows.Next() {
-		// Create a slice of empty interfaces to scan into
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
-
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, nil, err
-		}
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
 
-		// Convert byte slices (BLOBs) and other types to printable strings
-		for i, val := range values {
-			switch v := val.(type) {
-			case []byte:
-				values[i] = string(v)
-			case time.Time:
-				values[i] = v.Format(time.RFC3339)
-			case nil:
-				values[i] = "NULL"
-			}
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
-
-		results = append(results, values)
 	}
-
-	return columns, results, nil
+	return items
 }
 
-// --- Helper Functions ---
-
-func (a *App) renderTemplate(w http.ResponseWriter, tmplName string, data PageData) {
-	err := a.templates.ExecuteTemplate(w, tmplName, data)
+// runOnce runs -exec-file, -export-table or -export-all, closes app and
+// returns the exit status.
+func runOnce(app *explorer.App, execFile, exportTable string, exportAll bool, out, format string) int {
+	defer app.Close()
+	ctx := context.Background()
+	var err error
+	if exportAll {
+		err = app.ExportAll(ctx, out, format)
+	} else {
+		err = writeOutput(out, func(w io.Writer) error {
+			if exportTable != "" {
+				return app.ExportTable(ctx, w, exportTable, format)
+			}
+			query, err := os.ReadFile(execFile)
+			if err != nil {
+				return err
+			}
+			return app.ExecQuery(ctx, w, string(query), format)
+		})
+	}
 	if err != nil {
-		log.Printf("Error executing template %s: %v", tmplName, err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error: %v", err)
+		return 1
 	}
+	return 0
 }
 
-func (a *App) respondWithError(w http.ResponseWriter, code int, message string) {
-	a.respondWithJSON(w, code, map[string]string{"error": message})
-}
-
-func (a *App) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, err := json.Marshal(payload)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "Failed to marshal JSON response"}`))
-		return
+// writeOutput writes -out with write, or stdout if -out is empty.
+func writeOutput(out string, write func(w io.Writer) error) error {
+	if out == "" {
+		return write(os.Stdout)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(response)
+	return explorer.CreateFile(out, write)
 }